@@ -1,8 +1,21 @@
 package alertnotification
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"time"
+)
+
+// Severity of an alert, used to pick colours of the notification
+type Severity string
+
+// Severities supported by the notifications
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+	SeverityResolved Severity = "resolved"
 )
 
 // Alert struct for specify the ignoring error and the occuring error
@@ -10,6 +23,13 @@ type Alert struct {
 	Error            error
 	DoNotAlertErrors []error
 	Expandos         *Expandos
+	Severity         Severity
+	Context          map[string]string // extra facts shown in all notifications
+	Fingerprint      string            // groups occurrences into one alert, computed from Error when empty
+	SuppressionRules []SuppressionRule // errors not alerted, in addition to DoNotAlertErrors and the global rules
+	ThrottleDuration time.Duration     // throttle duration of the error, instead of the one of its severity or THROTTLE_DURATION
+
+	occurrences OccurrenceStats // throttled since the last alert, reported in the notifications
 }
 
 // NewAlert creates Alert struct instance
@@ -39,6 +59,9 @@ type Expandos struct {
 	MsTeamsAlertCardSubject string
 	MsTeamsCardSubject      string
 	MsTeamsError            string
+	MsTeamsThemeColor       string           // hex colour of the card, overrides the severity colour
+	MsTeamsActions          []MsTeamsAction  // buttons added to the configured ones
+	MsTeamsMentions         []MsTeamsMention // people or tags notified in addition to the configured ones
 }
 
 // AlertNotification is interface that all send notification function satify including send email
@@ -53,12 +76,23 @@ func DoSendNotification(alert AlertNotification) error {
 
 // Notify send and do throttling when error occur
 func (a *Alert) Notify() (err error) {
+	if d := getDigest(); d != nil {
+		// the digest groups the occurrences instead of throttling them
+		if !a.isThrottlingEnabled() || !a.isDoNotAlert() {
+			d.Add(a)
+		}
+		return
+	}
 	if a.shouldAlert() {
 		err := a.dispatch()
 		fmt.Println(err)
 		if err != nil {
+			// the occurrences are reported by the next alert
 			return err
 		}
+		if err := a.clearOccurrences(); err != nil {
+			fmt.Println(err)
+		}
 	}
 	return
 }
@@ -68,6 +102,8 @@ func (a *Alert) dispatch() (err error) {
 	if shouldMail() {
 		fmt.Println("Send mail....")
 		e := NewEmailConfig(a.Error, a.Expandos)
+		e.Context = a.Context
+		e.Occurrences = a.occurrences
 		err := e.Send()
 		if err != nil {
 			return err
@@ -76,15 +112,71 @@ func (a *Alert) dispatch() (err error) {
 
 	if shouldMsTeams() {
 		fmt.Println("SendTeams")
-		m := NewMsTeam(a.Error, a.Expandos)
+		m := newAlertMsTeam(a)
 		err := m.Send()
 		if err != nil {
 			return err
 		}
 	}
+
+	if shouldMsGraph() {
+		fmt.Println("Send Teams through Graph")
+		g := newAlertMsGraphTeams(a)
+		err := g.Send()
+		if err != nil {
+			return err
+		}
+		if a.isThrottlingEnabled() && os.Getenv("MS_GRAPH_AUTO_RESOLVE") != "false" {
+			a.resolveWhenStopped()
+		}
+	}
 	return
 }
 
+// Resolve marks the Teams thread of the error as resolved when Graph notifications are enabled.
+// It is called once the error did not occur for its throttle and grace durations, the application can call it sooner,
+// eg. when the failing call succeeds again
+func (a *Alert) Resolve() error {
+	if !shouldMsGraph() {
+		return nil
+	}
+	resolved := *a
+	resolved.Severity = SeverityResolved
+	g := newAlertMsGraphTeams(&resolved)
+	return g.Resolve()
+}
+
+// resolveWhenStopped resolves the thread of the alert once the error did not occur for its throttle and grace durations.
+// The check runs in this process, so the thread of an error alerted just before it exits stays open
+func (a *Alert) resolveWhenStopped() {
+	t := NewThrottler()
+	store, err := t.store()
+	if err != nil {
+		return
+	}
+	alert := *a
+	key := a.fingerprint()
+	window := t.ttl(t.throttleDuration(a))
+	alerted, _, _ := store.Get(key)
+	var check func()
+	check = func() {
+		if current, found, err := store.Get(key); err != nil || (found && !bytes.Equal(current, alerted)) {
+			// alerted again, the new alert checks the error
+			return
+		}
+		stats, err := readSuppressed(store, key)
+		if err != nil {
+			return
+		}
+		if wait := time.Until(stats.LastSeen.Add(window)); wait > 0 {
+			time.AfterFunc(wait, check)
+			return
+		}
+		alert.Resolve()
+	}
+	time.AfterFunc(window, check)
+}
+
 func (a *Alert) shouldAlert() bool {
 	if !a.isThrottlingEnabled() {
 		//Always alert when throttling is disabled.
@@ -95,22 +187,48 @@ func (a *Alert) shouldAlert() bool {
 		return false
 	}
 	t := NewThrottler()
-	return !t.IsThrottledOrGraced(a.Error)
+	throttled, occurrences := t.check(a.fingerprint(), t.throttleDuration(a))
+	a.occurrences = occurrences
+	return !throttled
+}
+
+// clearOccurrences forgets the suppressed occurrences reported by the sent alert
+func (a *Alert) clearOccurrences() error {
+	if a.occurrences.Suppressed == 0 {
+		return nil
+	}
+	t := NewThrottler()
+	store, err := t.store()
+	if err != nil {
+		return err
+	}
+	return clearSuppressed(store, a.fingerprint(), a.occurrences)
+}
+
+// fingerprint identifies the error in links and caches
+func (a *Alert) fingerprint() string {
+	if a.Fingerprint != "" {
+		return hashFingerprint(a.Fingerprint)
+	}
+	return Fingerprint(a.Error)
 }
 
 func (a *Alert) isDoNotAlert() bool {
+	rules := make([]SuppressionRule, 0, len(a.DoNotAlertErrors)+len(a.SuppressionRules))
 	for _, e := range a.DoNotAlertErrors {
-		if e.Error() == a.Error.Error() {
-			return true
-		}
+		rules = append(rules, SuppressMessage(e.Error()))
 	}
-	return false
+	return isSuppressed(a.Error, append(rules, a.SuppressionRules...))
 }
 
 func shouldMsTeams() bool {
 	return os.Getenv("MS_TEAMS_ALERT_ENABLED") == "true"
 }
 
+func shouldMsGraph() bool {
+	return os.Getenv("MS_GRAPH_ALERT_ENABLED") == "true"
+}
+
 func shouldMail() bool {
 	return os.Getenv("EMAIL_ALERT_ENABLED") == "true"
 }
@@ -0,0 +1,32 @@
+package alertnotification
+
+import "sort"
+
+// ContextField is a key/value pair of alert context rendered by the notifications
+type ContextField struct {
+	Key   string
+	Value string
+}
+
+// WithContext adds context such as request ID, user ID, region or build version to the alert
+func (a *Alert) WithContext(key string, value string) *Alert {
+	if a.Context == nil {
+		a.Context = map[string]string{}
+	}
+	a.Context[key] = value
+	return a
+}
+
+// contextFields returns the alert context sorted by key
+func contextFields(context map[string]string) []ContextField {
+	fields := []ContextField{}
+	keys := make([]string, 0, len(context))
+	for k := range context {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, ContextField{Key: k, Value: context[k]})
+	}
+	return fields
+}
@@ -0,0 +1,440 @@
+package alertnotification
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// digestSampleBytes is the size of the sample message of an error in digests
+const digestSampleBytes = 1000
+
+// DigestEntry is an error collected by a Digest
+type DigestEntry struct {
+	Fingerprint string
+	Count       int
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Sample      string // message of the first occurrence
+}
+
+// Digest collects the errors of Alert.Notify and sends a summary of them every interval, instead of one notification per error.
+// Errors are kept in memory, so each process sends its own digest
+type Digest struct {
+	// OnError receives the errors of the digests sent in the background, set it before Start.
+	// The errors of a failed channel are sent again with its next digest, and Stop returns the error of the last one
+	OnError func(err error)
+
+	interval   time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	current *digestBatch
+
+	flushMu sync.Mutex
+	pending map[string]*digestBatch // errors not sent to a channel yet, by channel name
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// digestBatch is the errors of one digest
+type digestBatch struct {
+	since   time.Time
+	entries map[string]*DigestEntry
+	dropped int // occurrences of errors over maxEntries
+}
+
+func newDigestBatch() *digestBatch {
+	return &digestBatch{since: time.Now(), entries: map[string]*DigestEntry{}}
+}
+
+// merge returns a new batch with the errors of b followed by the ones of newer, up to maxEntries errors
+func (b *digestBatch) merge(newer *digestBatch, maxEntries int) *digestBatch {
+	merged := &digestBatch{since: b.since, entries: map[string]*DigestEntry{}, dropped: b.dropped + newer.dropped}
+	for _, batch := range []*digestBatch{b, newer} {
+		for key, e := range batch.entries {
+			if current, ok := merged.entries[key]; ok {
+				current.Count += e.Count
+				current.LastSeen = e.LastSeen
+				continue
+			}
+			if len(merged.entries) >= maxEntries {
+				merged.dropped += e.Count
+				continue
+			}
+			entry := *e
+			merged.entries[key] = &entry
+		}
+	}
+	return merged
+}
+
+// digestChannel is a notification channel of digests
+type digestChannel struct {
+	name    string
+	enabled func() bool
+	send    func(s DigestSummary) error
+}
+
+var digestChannels = []digestChannel{
+	{name: "email", enabled: shouldMail, send: DigestSummary.sendEmail},
+	{name: "teams", enabled: shouldMsTeams, send: DigestSummary.sendMsTeams},
+	{name: "graph", enabled: shouldMsGraph, send: DigestSummary.sendMsGraph},
+}
+
+var (
+	digestMu      sync.RWMutex
+	defaultDigest *Digest
+)
+
+// SetDigest makes Alert.Notify collect errors into d instead of sending them, nil restores immediate notifications
+func SetDigest(d *Digest) {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+	defaultDigest = d
+}
+
+func getDigest() *Digest {
+	digestMu.RLock()
+	defer digestMu.RUnlock()
+	return defaultDigest
+}
+
+// NewDigest creates a digest sent every interval and listing up to maxEntries errors.
+// Zero values use DIGEST_INTERVAL_MINUTES (15) and DIGEST_MAX_ENTRIES (20)
+func NewDigest(interval time.Duration, maxEntries int) *Digest {
+	if interval <= 0 {
+		interval = time.Duration(getEnvInt("DIGEST_INTERVAL_MINUTES", 15)) * time.Minute
+	}
+	if maxEntries <= 0 {
+		maxEntries = getEnvInt("DIGEST_MAX_ENTRIES", 20)
+	}
+	return &Digest{
+		interval:   interval,
+		maxEntries: maxEntries,
+		current:    newDigestBatch(),
+		pending:    map[string]*digestBatch{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Add collects an occurrence of the error of a
+func (d *Digest) Add(a *Alert) {
+	now := time.Now()
+	key := a.fingerprint()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.current.entries[key]; ok {
+		e.Count++
+		e.LastSeen = now
+		return
+	}
+	if len(d.current.entries) >= d.maxEntries {
+		d.current.dropped++
+		return
+	}
+	d.current.entries[key] = &DigestEntry{
+		Fingerprint: key,
+		Count:       1,
+		FirstSeen:   now,
+		LastSeen:    now,
+		Sample:      truncateMiddle(fmt.Sprintf("%+v", a.Error), digestSampleBytes),
+	}
+}
+
+// Entries returns the errors collected since the last digest, the most frequent first
+func (d *Digest) Entries() []DigestEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return sortedEntries(d.current.entries)
+}
+
+func sortedEntries(entries map[string]*DigestEntry) []DigestEntry {
+	sorted := make([]DigestEntry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, *e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].FirstSeen.Before(sorted[j].FirstSeen)
+	})
+	return sorted
+}
+
+// Start sends the digest every interval in the background, until Stop
+func (d *Digest) Start() {
+	d.startOnce.Do(func() {
+		go d.run()
+	})
+}
+
+func (d *Digest) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if err := d.Flush(); err != nil && d.OnError != nil {
+				d.OnError(err)
+			}
+		}
+	}
+}
+
+// Stop ends the background sending and flushes the remaining errors, call it on shutdown
+func (d *Digest) Stop() error {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		started := true
+		d.startOnce.Do(func() { started = false })
+		if started {
+			<-d.done
+		}
+	})
+	return d.Flush()
+}
+
+// Flush sends the collected errors to all registered channels now. Nothing is sent without error.
+// When a channel fails, its errors are sent again with the next digest of this channel only
+func (d *Digest) Flush() error {
+	d.flushMu.Lock()
+	defer d.flushMu.Unlock()
+	d.mu.Lock()
+	batch := d.current
+	d.current = newDigestBatch()
+	d.mu.Unlock()
+
+	var errs []error
+	for _, c := range digestChannels {
+		if !c.enabled() {
+			continue
+		}
+		toSend := batch
+		if pending, ok := d.pending[c.name]; ok {
+			toSend = pending.merge(batch, d.maxEntries)
+		}
+		if len(toSend.entries) == 0 {
+			continue
+		}
+		if err := c.send(newDigestSummary(toSend)); err != nil {
+			d.pending[c.name] = toSend
+			errs = append(errs, fmt.Errorf("digest %v: %w", c.name, err))
+			continue
+		}
+		delete(d.pending, c.name)
+	}
+	return errors.Join(errs...)
+}
+
+// DigestSummary is the data of digest notifications
+type DigestSummary struct {
+	Subject  string
+	Hostname string
+	AppName  string
+	AppEnv   string
+	Since    time.Time
+	Time     time.Time
+	Entries  []DigestEntry
+	Dropped  int // occurrences of errors not listed
+}
+
+func newDigestSummary(batch *digestBatch) DigestSummary {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "hostname_unknown"
+	}
+	now := time.Now()
+	entries := sortedEntries(batch.entries)
+	return DigestSummary{
+		Subject:  fmt.Sprintf("%d errors in the last %v", len(entries), now.Sub(batch.since).Round(time.Second)),
+		Hostname: hostname,
+		AppName:  os.Getenv("APP_NAME"),
+		AppEnv:   os.Getenv("APP_ENV"),
+		Since:    batch.since,
+		Time:     now,
+		Entries:  entries,
+		Dropped:  batch.dropped,
+	}
+}
+
+func (s DigestSummary) sendEmail() error {
+	body, err := s.emailBody()
+	if err != nil {
+		return err
+	}
+	subject := s.Subject
+	if prefix := os.Getenv("EMAIL_SUBJECT"); prefix != "" {
+		subject = prefix + ": " + subject
+	}
+	e := NewEmailConfig(nil, &Expandos{EmailSubject: subject, EmailBody: body})
+	return e.Send()
+}
+
+func (s DigestSummary) sendMsTeams() error {
+	m := s.msTeam()
+	return m.Send()
+}
+
+func (s DigestSummary) sendMsGraph() error {
+	// all digests are replies in one thread
+	g := newMsGraphTeams(s.msTeam(), "digest")
+	return g.Send()
+}
+
+const digestEmailTemplate = `<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:0;background-color:#f4f4f4;font-family:Segoe UI,Helvetica,Arial,sans-serif;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="background-color:#f4f4f4;padding:24px 0;">
+<tr><td align="center">
+<table role="presentation" width="640" cellpadding="0" cellspacing="0" style="background-color:#ffffff;border-top:4px solid #ffb900;">
+<tr><td style="padding:20px 24px;font-size:20px;font-weight:bold;color:#333333;">{{.Subject}}</td></tr>
+<tr><td style="padding:0 24px 8px 24px;font-size:14px;color:#333333;">{{.Hostname}} {{.AppName}} {{.AppEnv}}, {{.Since.Format "2006-01-02 15:04:05"}} to {{.Time.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+{{- range .Entries}}
+<tr><td style="padding:8px 24px;">
+<table role="presentation" width="100%" cellpadding="6" cellspacing="0" style="border-collapse:collapse;font-size:14px;color:#333333;">
+<tr><td style="width:160px;font-weight:bold;border-bottom:1px solid #eeeeee;">Occurrences</td><td style="border-bottom:1px solid #eeeeee;">{{.Count}}</td></tr>
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">First seen</td><td style="border-bottom:1px solid #eeeeee;">{{.FirstSeen.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">Last seen</td><td style="border-bottom:1px solid #eeeeee;">{{.LastSeen.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+</table>
+<pre style="margin:8px 0 0 0;padding:12px;background-color:#f8f8f8;border:1px solid #eeeeee;font-size:12px;white-space:pre-wrap;word-wrap:break-word;">{{.Sample}}</pre>
+</td></tr>
+{{- end}}
+{{- if .Dropped}}
+<tr><td style="padding:8px 24px;font-size:14px;color:#333333;">{{.Dropped}} more occurrences of other errors</td></tr>
+{{- end}}
+<tr><td style="padding:8px 24px 24px 24px;"></td></tr>
+</table>
+</td></tr>
+</table>
+</body>
+</html>`
+
+var digestEmailTmpl = template.Must(template.New("digest").Parse(digestEmailTemplate))
+
+func (s DigestSummary) emailBody() (string, error) {
+	var body bytes.Buffer
+	if err := digestEmailTmpl.Execute(&body, s); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+// digestMinSampleBytes is the size under which samples are not shortened to fit the card, errors are dropped instead
+const digestMinSampleBytes = 200
+
+// msTeam creates the digest card within MS_TEAMS_MAX_PAYLOAD_BYTES. The samples are shortened first,
+// then the least frequent errors are counted in Dropped instead of being listed
+func (s DigestSummary) msTeam() MsTeam {
+	card := s.buildMsTeam()
+	limit := getMsTeamsMaxPayloadBytes()
+	for limit > 0 && len(s.Entries) != 0 {
+		size, err := card.size()
+		if err != nil || size <= limit {
+			break
+		}
+		longest := 0
+		for _, e := range s.Entries {
+			if len(e.Sample) > longest {
+				longest = len(e.Sample)
+			}
+		}
+		entries := append([]DigestEntry{}, s.Entries...)
+		if longest > digestMinSampleBytes {
+			maxBytes := longest / 2
+			if maxBytes < digestMinSampleBytes {
+				maxBytes = digestMinSampleBytes
+			}
+			for i := range entries {
+				entries[i].Sample = truncateMiddle(entries[i].Sample, maxBytes)
+			}
+		} else {
+			s.Dropped += entries[len(entries)-1].Count
+			entries = entries[:len(entries)-1]
+		}
+		s.Entries = entries
+		card = s.buildMsTeam()
+	}
+	return card
+}
+
+// buildMsTeam creates the digest card, one fact set and sample per error
+func (s DigestSummary) buildMsTeam() MsTeam {
+	accentColor, titleColor := cardColors(SeverityWarning, nil)
+	hostname := s.Hostname + " " + s.AppName
+	body := []interface{}{
+		textBlock{
+			Type:   "TextBlock",
+			Text:   s.Subject,
+			ID:     "title",
+			Size:   "large",
+			Weight: "bolder",
+			Color:  titleColor,
+		},
+		textBlock{
+			Type: "TextBlock",
+			Text: hostname,
+			Wrap: true,
+		},
+	}
+	for i, e := range s.Entries {
+		body = append(body,
+			factSet{
+				Type: "FactSet",
+				Facts: []fact{
+					{Title: "Occurrences:", Value: fmt.Sprint(e.Count)},
+					{Title: "First seen:", Value: e.FirstSeen.Format(time.RFC3339)},
+					{Title: "Last seen:", Value: e.LastSeen.Format(time.RFC3339)},
+				},
+				ID: fmt.Sprintf("acFactSet%d", i),
+			},
+			codeBlock{
+				Type:        "CodeBlock",
+				CodeSnippet: e.Sample,
+				FontType:    "monospace",
+				Wrap:        true,
+			},
+		)
+	}
+	if s.Dropped != 0 {
+		body = append(body, textBlock{
+			Type: "TextBlock",
+			Text: fmt.Sprintf("%d more occurrences of other errors", s.Dropped),
+			Wrap: true,
+		})
+	}
+
+	return MsTeam{
+		Type: "message",
+		Attachments: []attachment{
+			{
+				ContentType: "application/vnd.microsoft.card.adaptive",
+				ContentURL:  nil,
+				Content: cardContent{
+					Schema:      "http://adaptivecards.io/schemas/adaptive-card.json",
+					Type:        "AdaptiveCard",
+					Version:     "1.4",
+					AccentColor: accentColor,
+					Body:        body,
+					Actions:     []action{},
+					MSTeams: msTeams{
+						Width: "Full",
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,264 @@
+package alertnotification
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDigest_Add(t *testing.T) {
+	d := NewDigest(time.Minute, 2)
+	for _, msg := range []string{"timeout after 10ms", "connection refused", "timeout after 12ms", "disk full"} {
+		a := NewAlert(errors.New(msg), nil)
+		d.Add(&a)
+	}
+	entries := d.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Digest.Entries() = %+v, want 2 entries", entries)
+	}
+	if entries[0].Count != 2 || entries[0].Sample != "timeout after 10ms" {
+		t.Errorf("Digest.Entries()[0] = %+v, want the timeout twice", entries[0])
+	}
+	if entries[1].Count != 1 || entries[1].Sample != "connection refused" {
+		t.Errorf("Digest.Entries()[1] = %+v", entries[1])
+	}
+	if d.current.dropped != 1 {
+		t.Errorf("Digest dropped = %v, want 1 over maxEntries", d.current.dropped)
+	}
+}
+
+// digestServer counts the digest cards sent to the Teams webhook
+func digestServer(t *testing.T, status int) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var cards []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		cards = append(cards, string(body))
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	resetHTTPClient(t)
+	t.Setenv("EMAIL_ALERT_ENABLED", "")
+	t.Setenv("MS_GRAPH_ALERT_ENABLED", "")
+	t.Setenv("MS_TEAMS_ALERT_ENABLED", "true")
+	t.Setenv("MS_TEAMS_WEBHOOK", server.URL)
+	t.Setenv("MS_TEAMS_MAX_RETRIES", "0")
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, cards...)
+	}
+}
+
+func TestDigest_Flush(t *testing.T) {
+	_, cards := digestServer(t, http.StatusAccepted)
+	d := NewDigest(time.Minute, 0)
+	if err := d.Flush(); err != nil || len(cards()) != 0 {
+		t.Fatalf("Digest.Flush() without error = %v, sent %v cards", err, len(cards()))
+	}
+
+	for i := 0; i < 3; i++ {
+		a := NewAlert(errors.New("db timeout"), nil)
+		d.Add(&a)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	sent := cards()
+	if len(sent) != 1 {
+		t.Fatalf("Digest.Flush() sent %v cards, want 1", len(sent))
+	}
+	var card MsTeam
+	if err := json.Unmarshal([]byte(sent[0]), &card); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Occurrences:"`, `"value":"3"`, `"db timeout"`, "1 errors in the last"} {
+		if !strings.Contains(sent[0], want) {
+			t.Errorf("Digest.Flush() card = %v, want it to contain %v", sent[0], want)
+		}
+	}
+	if len(d.Entries()) != 0 {
+		t.Errorf("Digest.Flush() did not reset the entries")
+	}
+}
+
+func TestDigest_Flush_failure(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	var cards []string
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		cards = append(cards, string(body))
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer teams.Close()
+	smtpServer := newFakeSMTPServer(t)
+	host, port := smtpServer.hostPort()
+	resetHTTPClient(t)
+	t.Setenv("EMAIL_ALERT_ENABLED", "true")
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("EMAIL_USERNAME", "")
+	t.Setenv("EMAIL_SENDER", "test@example.com")
+	t.Setenv("EMAIL_RECEIVERS", "receiver.test@example.com")
+	t.Setenv("MS_GRAPH_ALERT_ENABLED", "")
+	t.Setenv("MS_TEAMS_ALERT_ENABLED", "true")
+	t.Setenv("MS_TEAMS_WEBHOOK", teams.URL)
+	t.Setenv("MS_TEAMS_MAX_RETRIES", "0")
+
+	d := NewDigest(time.Minute, 0)
+	first := NewAlert(errors.New("db timeout"), nil)
+	d.Add(&first)
+	if err := d.Flush(); err == nil || !strings.Contains(err.Error(), "digest teams") {
+		t.Fatalf("Digest.Flush() error = %v, want the teams error", err)
+	}
+
+	status.Store(http.StatusAccepted)
+	second := NewAlert(errors.New("disk full"), nil)
+	d.Add(&second)
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	smtpServer.mu.Lock()
+	emails := append([]string{}, smtpServer.messages...)
+	smtpServer.mu.Unlock()
+	if len(emails) != 2 {
+		t.Fatalf("Digest.Flush() sent %v emails, want 2", len(emails))
+	}
+	parts := strings.SplitN(emails[1], "\r\n\r\n", 2)
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(parts[1], "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "db timeout") || !strings.Contains(string(body), "disk full") {
+		t.Errorf("second digest email = %v, want only the new error", string(body))
+	}
+	if len(cards) != 2 || !strings.Contains(cards[1], "db timeout") || !strings.Contains(cards[1], "disk full") {
+		t.Errorf("second digest card = %v, want the failed and the new errors", cards)
+	}
+	if err := d.Flush(); err != nil || len(cards) != 2 {
+		t.Errorf("Digest.Flush() resent the sent errors, err = %v, cards = %v", err, len(cards))
+	}
+}
+
+func TestDigest_StartStop(t *testing.T) {
+	_, cards := digestServer(t, http.StatusAccepted)
+	t.Setenv("THROTTLE_ENABLED", "false")
+	d := NewDigest(10*time.Millisecond, 0)
+	SetDigest(d)
+	defer SetDigest(nil)
+	d.Start()
+
+	a := NewAlert(errors.New("first error"), nil)
+	if err := a.Notify(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(cards()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(cards()) != 1 {
+		t.Fatalf("Digest sent %v cards after the interval, want 1", len(cards()))
+	}
+
+	b := NewAlert(errors.New("last error"), nil)
+	b.Notify()
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	sent := cards()
+	if len(sent) != 2 || !strings.Contains(sent[1], "last error") {
+		t.Errorf("Digest.Stop() did not flush the last error, cards = %v", sent)
+	}
+}
+
+func TestDigestSummary_emailBody(t *testing.T) {
+	now := time.Now()
+	s := newDigestSummary(&digestBatch{
+		since:   now.Add(-15 * time.Minute),
+		entries: map[string]*DigestEntry{"key": {Count: 412, FirstSeen: now, LastSeen: now, Sample: "<nil> pointer"}},
+		dropped: 3,
+	})
+	body, err := s.emailBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1 errors in the last 15m0s", ">412<", "&lt;nil&gt; pointer", "3 more occurrences"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("DigestSummary.emailBody() = %v, want it to contain %q", body, want)
+		}
+	}
+}
+
+func TestDigestSummary_msTeam_payloadLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       string
+		wantDropped bool
+	}{
+		{name: "shorten_samples", limit: "28000"},
+		{name: "drop_entries", limit: "6000", wantDropped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MS_TEAMS_MAX_PAYLOAD_BYTES", tt.limit)
+			batch := newDigestBatch()
+			for i := 0; i < 40; i++ {
+				sample := strings.Repeat(`"quoted" <tag>\n`, 100)[:digestSampleBytes]
+				batch.entries[fmt.Sprint(i)] = &DigestEntry{Fingerprint: fmt.Sprint(i), Count: 40 - i, Sample: sample}
+			}
+			card := newDigestSummary(batch).msTeam()
+			size, err := card.size()
+			if err != nil {
+				t.Fatal(err)
+			}
+			limit, _ := strconv.Atoi(tt.limit)
+			if size > limit {
+				t.Errorf("DigestSummary.msTeam() size = %v, want at most %v", size, limit)
+			}
+			body := card.Attachments[0].Content.(cardContent).Body
+			dropped := strings.Contains(fmt.Sprint(body), "more occurrences of other errors")
+			if dropped != tt.wantDropped {
+				t.Errorf("DigestSummary.msTeam() dropped errors = %v, want %v", dropped, tt.wantDropped)
+			}
+			if len(body) < 4 {
+				t.Errorf("DigestSummary.msTeam() lists no error")
+			}
+		})
+	}
+}
+
+func TestDigest_OnError(t *testing.T) {
+	digestServer(t, http.StatusInternalServerError)
+	d := NewDigest(10*time.Millisecond, 0)
+	errs := make(chan error, 10)
+	d.OnError = func(err error) { errs <- err }
+	a := NewAlert(errors.New("db timeout"), nil)
+	d.Add(&a)
+	d.Start()
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "digest teams") {
+			t.Errorf("Digest.OnError() received %v, want the teams error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Digest.OnError() was not called")
+	}
+	if err := d.Stop(); err == nil {
+		t.Errorf("Digest.Stop() error = nil, want the error of the unsent digest")
+	}
+}
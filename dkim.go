@@ -0,0 +1,139 @@
+package alertnotification
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// defaultDKIMHeaders are the header fields signed when EMAIL_DKIM_HEADERS is not set
+var defaultDKIMHeaders = []string{
+	"From",
+	"To",
+	"Subject",
+	"Date",
+	"MIME-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+// DKIMConfig is DKIM signing setting struct
+type DKIMConfig struct {
+	Domain         string
+	Selector       string
+	Headers        []string      // header fields to sign, "From" is always added
+	PrivateKey     string        // PEM encoded RSA or Ed25519 private key
+	PrivateKeyFile string        // used when PrivateKey is empty
+	Signer         crypto.Signer // takes precedence over PrivateKey and PrivateKeyFile
+}
+
+// NewDKIMConfig creates DKIMConfig from env. It returns nil when DKIM signing is not configured
+func NewDKIMConfig() *DKIMConfig {
+	config := DKIMConfig{
+		Domain:         os.Getenv("EMAIL_DKIM_DOMAIN"),
+		Selector:       os.Getenv("EMAIL_DKIM_SELECTOR"),
+		PrivateKey:     os.Getenv("EMAIL_DKIM_PRIVATE_KEY"),
+		PrivateKeyFile: os.Getenv("EMAIL_DKIM_PRIVATE_KEY_FILE"),
+		Headers:        defaultDKIMHeaders,
+	}
+	if len(config.Domain) == 0 || len(config.Selector) == 0 {
+		return nil
+	}
+	if headers := os.Getenv("EMAIL_DKIM_HEADERS"); len(headers) != 0 {
+		config.Headers = nil
+		for _, h := range strings.Split(headers, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				config.Headers = append(config.Headers, h)
+			}
+		}
+	}
+	return &config
+}
+
+// Sign returns the message with a DKIM-Signature header prepended
+func (dc *DKIMConfig) Sign(message []byte) ([]byte, error) {
+	signer, err := dc.signer()
+	if err != nil {
+		return nil, err
+	}
+	options := &dkim.SignOptions{
+		Domain:                 dc.Domain,
+		Selector:               dc.Selector,
+		Signer:                 signer,
+		Hash:                   crypto.SHA256,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             dc.headerKeys(),
+	}
+	var signed bytes.Buffer
+	if err := dkim.Sign(&signed, bytes.NewReader(message), options); err != nil {
+		return nil, err
+	}
+	return signed.Bytes(), nil
+}
+
+func (dc *DKIMConfig) headerKeys() []string {
+	keys := []string{}
+	hasFrom := false
+	for _, h := range dc.Headers {
+		if strings.EqualFold(h, "From") {
+			hasFrom = true
+		}
+		keys = append(keys, h)
+	}
+	if !hasFrom {
+		keys = append([]string{"From"}, keys...)
+	}
+	return keys
+}
+
+func (dc *DKIMConfig) signer() (crypto.Signer, error) {
+	if dc.Signer != nil {
+		return dc.Signer, nil
+	}
+	keyPEM := []byte(dc.PrivateKey)
+	if len(strings.TrimSpace(dc.PrivateKey)) == 0 {
+		if len(dc.PrivateKeyFile) == 0 {
+			return nil, errors.New("dkim: private key is not set. EMAIL_DKIM_PRIVATE_KEY or EMAIL_DKIM_PRIVATE_KEY_FILE is required")
+		}
+		b, err := os.ReadFile(dc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM = b
+	}
+	return parseDKIMPrivateKey(keyPEM)
+}
+
+func parseDKIMPrivateKey(keyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("dkim: private key is not PEM encoded")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		}
+		return nil, fmt.Errorf("dkim: unsupported private key type %T", key)
+	}
+	return nil, fmt.Errorf("dkim: unsupported PEM block type %q", block.Type)
+}
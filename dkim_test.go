@@ -0,0 +1,156 @@
+package alertnotification
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+const testDKIMMessage = "To: receiver.test@example.com\r\n" +
+	"From: test@example.com\r\n" +
+	"Subject: test subject\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/html; charset=\"UTF-8\"\r\n" +
+	"\r\n" +
+	"Error: something went wrong\r\n"
+
+func TestDKIMConfig_Sign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPKCS8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "dkim.pem")
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edPKCS8}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  DKIMConfig
+		public  crypto.PublicKey
+		wantErr bool
+	}{
+		{
+			name: "rsa_pem",
+			config: DKIMConfig{
+				Domain:     "example.com",
+				Selector:   "alert",
+				Headers:    defaultDKIMHeaders,
+				PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})),
+			},
+			public: &rsaKey.PublicKey,
+		},
+		{
+			name: "ed25519_file",
+			config: DKIMConfig{
+				Domain:         "example.com",
+				Selector:       "alert",
+				Headers:        []string{"Subject"},
+				PrivateKeyFile: keyFile,
+			},
+			public: edKey.Public(),
+		},
+		{
+			name: "no_key",
+			config: DKIMConfig{
+				Domain:   "example.com",
+				Selector: "alert",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_pem",
+			config: DKIMConfig{
+				Domain:     "example.com",
+				Selector:   "alert",
+				PrivateKey: "not a key",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := tt.config.Sign([]byte(testDKIMMessage))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DKIMConfig.Sign() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			verifications, err := dkim.VerifyWithOptions(bytes.NewReader(signed), &dkim.VerifyOptions{
+				LookupTXT: func(domain string) ([]string, error) {
+					return []string{dkimTXTRecord(t, tt.public)}, nil
+				},
+			})
+			if err != nil {
+				t.Fatalf("dkim.Verify() error = %v", err)
+			}
+			if len(verifications) != 1 || verifications[0].Err != nil {
+				t.Fatalf("dkim.Verify() = %+v, want one valid signature", verifications)
+			}
+			if verifications[0].Domain != "example.com" {
+				t.Errorf("dkim.Verify() domain = %v, want example.com", verifications[0].Domain)
+			}
+		})
+	}
+}
+
+func TestNewDKIMConfig(t *testing.T) {
+	setEnv()
+	os.Setenv("EMAIL_DKIM_DOMAIN", "")
+	os.Setenv("EMAIL_DKIM_SELECTOR", "")
+	if got := NewDKIMConfig(); got != nil {
+		t.Errorf("NewDKIMConfig() = %+v, want nil", got)
+	}
+
+	os.Setenv("EMAIL_DKIM_DOMAIN", "example.com")
+	os.Setenv("EMAIL_DKIM_SELECTOR", "alert")
+	os.Setenv("EMAIL_DKIM_HEADERS", "Subject, To")
+	defer func() {
+		os.Unsetenv("EMAIL_DKIM_DOMAIN")
+		os.Unsetenv("EMAIL_DKIM_SELECTOR")
+		os.Unsetenv("EMAIL_DKIM_HEADERS")
+	}()
+	got := NewDKIMConfig()
+	if got == nil {
+		t.Fatal("NewDKIMConfig() = nil, want config")
+	}
+	if want := []string{"From", "Subject", "To"}; !reflect.DeepEqual(got.headerKeys(), want) {
+		t.Errorf("DKIMConfig.headerKeys() = %v, want %v", got.headerKeys(), want)
+	}
+}
+
+func dkimTXTRecord(t *testing.T, public crypto.PublicKey) string {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(k)
+	}
+	t.Fatalf("unsupported public key %T", public)
+	return ""
+}
@@ -1,26 +1,36 @@
 package alertnotification
 
 import (
+	"bytes"
 	"encoding/base64"
-	"errors"
 	"fmt"
-	"net/smtp"
+	"mime/multipart"
+	"net/textproto"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // EmailConfig is email setting struct
 type EmailConfig struct {
-	Username     string
-	Password     string
-	Host         string
-	Port         string
-	Sender       string
-	EnvelopeFrom string
-	Receivers    []string // Can use comma for multiple email
-	Subject      string
-	ErrorObj     error
-	Expandos     *Expandos // can modify mail subject and content on demand
+	Username        string
+	Password        string
+	Host            string
+	Port            string
+	Sender          string
+	EnvelopeFrom    string
+	Receivers       []string // Can use comma for multiple email
+	Subject         string
+	ErrorObj        error
+	Expandos        *Expandos   // can modify mail subject and content on demand
+	DKIM            *DKIMConfig // sign outgoing mail when set
+	Pool            *SMTPPool   // shared default pool is used when nil
+	TemplateFiles   []string    // html/template files for the body, first one is executed
+	Context         map[string]string
+	Occurrences     OccurrenceStats // throttled since the last alert of the error
+	MaxErrorBytes   int             // error text longer than this is shortened in the body, 0 means no limit
+	AttachFullError bool            // attach the full error as error.txt when it is shortened
 }
 
 func getReceivers() []string {
@@ -35,16 +45,24 @@ func getReceivers() []string {
 // NewEmailConfig create new EmailConfig struct
 func NewEmailConfig(err error, expandos *Expandos) EmailConfig {
 	config := EmailConfig{
-		Username:     os.Getenv("EMAIL_USERNAME"),
-		Password:     os.Getenv("EMAIL_PASSWORD"),
-		Host:         os.Getenv("SMTP_HOST"),
-		Port:         os.Getenv("SMTP_PORT"),
-		Sender:       os.Getenv("EMAIL_SENDER"),
-		EnvelopeFrom: os.Getenv("EMAIL_ENVELOPE_FROM"),
-		Subject:      os.Getenv("EMAIL_SUBJECT"),
-		Receivers:    getReceivers(),
-		ErrorObj:     err,
-		Expandos:     expandos,
+		Username:        os.Getenv("EMAIL_USERNAME"),
+		Password:        os.Getenv("EMAIL_PASSWORD"),
+		Host:            os.Getenv("SMTP_HOST"),
+		Port:            os.Getenv("SMTP_PORT"),
+		Sender:          os.Getenv("EMAIL_SENDER"),
+		EnvelopeFrom:    os.Getenv("EMAIL_ENVELOPE_FROM"),
+		Subject:         os.Getenv("EMAIL_SUBJECT"),
+		Receivers:       getReceivers(),
+		ErrorObj:        err,
+		Expandos:        expandos,
+		DKIM:            NewDKIMConfig(),
+		TemplateFiles:   getTemplateFiles(),
+		AttachFullError: os.Getenv("EMAIL_ATTACH_FULL_ERROR") == "true",
+	}
+	if len(os.Getenv("EMAIL_MAX_ERROR_BYTES")) != 0 {
+		if maxBytes, err := strconv.Atoi(os.Getenv("EMAIL_MAX_ERROR_BYTES")); err == nil {
+			config.MaxErrorBytes = maxBytes
+		}
 	}
 	if len(strings.TrimSpace(config.EnvelopeFrom)) == 0 {
 		config.EnvelopeFrom = config.Sender
@@ -55,73 +73,99 @@ func NewEmailConfig(err error, expandos *Expandos) EmailConfig {
 // Send Alert email
 func (ec *EmailConfig) Send() error {
 	fmt.Println("sending email ....")
-	var err error
-	if ec.Receivers == nil {
-		return errors.New("notification receivers are empty")
+	addresses, err := ec.parseAddresses()
+	if err != nil {
+		return err
 	}
-	r := strings.NewReplacer("\r\n", "", "\r", "", "\n", "", "%0a", "", "%0d", "")
-
-	messageDetail := "Error: \r\n" + fmt.Sprintf("%+v", ec.ErrorObj)
 
 	// update body and subject dynamically
-	if ec.Expandos != nil {
-		if ec.Expandos.EmailBody != "" {
-			messageDetail = ec.Expandos.EmailBody
+	if ec.Expandos != nil && ec.Expandos.EmailSubject != "" {
+		ec.Subject = ec.Expandos.EmailSubject
+	}
+	var messageDetail, fullError string
+	if ec.Expandos != nil && ec.Expandos.EmailBody != "" {
+		messageDetail = ec.Expandos.EmailBody
+	} else {
+		errMsg := fmt.Sprintf("%+v", ec.ErrorObj)
+		if ec.MaxErrorBytes > 0 && len(errMsg) > ec.MaxErrorBytes {
+			if ec.AttachFullError {
+				fullError = errMsg
+			}
+			errMsg = truncateMiddle(errMsg, ec.MaxErrorBytes)
 		}
-		if ec.Expandos.EmailSubject != "" {
-			ec.Subject = ec.Expandos.EmailSubject
+		data := newEmailTemplateData(ec.Subject, errMsg)
+		data.Context = contextFields(ec.Context)
+		data.OccurrenceCount = ec.Occurrences.Count()
+		data.SuppressedCount = ec.Occurrences.Suppressed
+		data.FirstSeen = ec.Occurrences.FirstSeen
+		if messageDetail, err = ec.renderBody(data); err != nil {
+			return err
 		}
 	}
 
-	message := "To: " + strings.Join(ec.Receivers, ", ") + "\r\n" +
-		"From: " + ec.Sender + "\r\n" +
-		"Subject: " + ec.Subject + "\r\n" +
-		"Content-Type: text/html; charset=\"UTF-8\"\r\n" +
-		"Content-Transfer-Encoding: base64\r\n" +
-		"\r\n" + base64.StdEncoding.EncodeToString([]byte(messageDetail))
-
-	if len(strings.TrimSpace(ec.Username)) != 0 {
-		stmpAuth := smtp.PlainAuth("", ec.Username, ec.Password, ec.Host)
-
-		err = smtp.SendMail(
-			ec.Host+":"+ec.Port,
-			stmpAuth,
-			ec.EnvelopeFrom,
-			ec.Receivers,
-			[]byte(message),
-		)
-		return err
-	}
-	fmt.Println("Send with localhost. ......")
-	conn, err := smtp.Dial(ec.Host + ":" + ec.Port)
-	if err != nil {
-		return err
-	}
+	message := ec.buildMessage(addresses, messageDetail, fullError)
 
-	defer conn.Close()
-	if err = conn.Mail(r.Replace(ec.EnvelopeFrom)); err != nil {
-		return err
-	}
-	// format receiver email
-	for i := range ec.Receivers {
-		ec.Receivers[i] = r.Replace(ec.Receivers[i])
-		if err = conn.Rcpt(ec.Receivers[i]); err != nil {
+	// sign before the message is handed to the DATA command
+	if ec.DKIM != nil {
+		if message, err = ec.DKIM.Sign(message); err != nil {
 			return err
 		}
 	}
 
-	w, err := conn.Data()
-	if err != nil {
-		return err
+	receivers := make([]string, 0, len(addresses.To))
+	for _, to := range addresses.To {
+		receivers = append(receivers, to.Address)
 	}
-	_, err = w.Write([]byte(message))
-	if err != nil {
-		return err
+
+	pool := ec.Pool
+	if pool == nil {
+		pool = defaultSMTPPool()
 	}
-	err = w.Close()
-	if err != nil {
-		return err
+	return pool.SendMail(ec.Host, ec.Port, ec.Username, ec.Password, addresses.EnvelopeFrom, receivers, message)
+}
+
+// buildMessage creates the MIME message. The full error is attached as error.txt when not empty
+func (ec *EmailConfig) buildMessage(addresses *emailAddresses, body string, fullError string) []byte {
+	header := "To: " + joinAddresses(addresses.To) + "\r\n" +
+		"From: " + addresses.From.String() + "\r\n" +
+		"Subject: " + encodeHeader(ec.Subject) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n"
+	encodedBody := wrapBase64(base64.StdEncoding.EncodeToString([]byte(body)))
+	if fullError == "" {
+		return []byte(header +
+			"Content-Type: text/html; charset=\"UTF-8\"\r\n" +
+			"Content-Transfer-Encoding: base64\r\n" +
+			"\r\n" + encodedBody)
 	}
-	return conn.Quit()
 
+	var parts bytes.Buffer
+	w := multipart.NewWriter(&parts)
+	html, _ := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/html; charset="UTF-8"`},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	html.Write([]byte(encodedBody))
+	attachment, _ := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/plain; charset="UTF-8"`},
+		"Content-Disposition":       {`attachment; filename="error.txt"`},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	attachment.Write([]byte(wrapBase64(base64.StdEncoding.EncodeToString([]byte(fullError)))))
+	w.Close()
+	return []byte(header +
+		"Content-Type: multipart/mixed; boundary=\"" + w.Boundary() + "\"\r\n" +
+		"\r\n" + parts.String())
+}
+
+// wrapBase64 breaks encoded body into 76 chars lines so relays do not rewrap it and break signatures
+func wrapBase64(encoded string) string {
+	const lineLength = 76
+	var b strings.Builder
+	for len(encoded) > lineLength {
+		b.WriteString(encoded[:lineLength] + "\r\n")
+		encoded = encoded[lineLength:]
+	}
+	b.WriteString(encoded)
+	return b.String()
 }
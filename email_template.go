@@ -0,0 +1,108 @@
+package alertnotification
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EmailTemplateData is the data available in email body templates
+type EmailTemplateData struct {
+	Subject         string
+	Hostname        string
+	AppName         string
+	AppEnv          string
+	Time            time.Time
+	OccurrenceCount int
+	SuppressedCount int
+	FirstSeen       time.Time // first suppressed occurrence, zero without SuppressedCount
+	Context         []ContextField
+	Error           string
+}
+
+const defaultEmailTemplate = `<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:0;background-color:#f4f4f4;font-family:Segoe UI,Helvetica,Arial,sans-serif;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="background-color:#f4f4f4;padding:24px 0;">
+<tr><td align="center">
+<table role="presentation" width="640" cellpadding="0" cellspacing="0" style="background-color:#ffffff;border-top:4px solid #bf0000;">
+<tr><td style="padding:20px 24px;font-size:20px;font-weight:bold;color:#bf0000;">{{.Subject}}</td></tr>
+<tr><td style="padding:0 24px;">
+<table role="presentation" width="100%" cellpadding="6" cellspacing="0" style="border-collapse:collapse;font-size:14px;color:#333333;">
+<tr><td style="width:160px;font-weight:bold;border-bottom:1px solid #eeeeee;">Host</td><td style="border-bottom:1px solid #eeeeee;">{{.Hostname}}</td></tr>
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">App</td><td style="border-bottom:1px solid #eeeeee;">{{.AppName}}</td></tr>
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">Env</td><td style="border-bottom:1px solid #eeeeee;">{{.AppEnv}}</td></tr>
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">Time</td><td style="border-bottom:1px solid #eeeeee;">{{.Time.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">Occurrences</td><td style="border-bottom:1px solid #eeeeee;">{{.OccurrenceCount}}</td></tr>
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">Suppressed</td><td style="border-bottom:1px solid #eeeeee;">{{.SuppressedCount}}</td></tr>
+{{- if .SuppressedCount}}
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">First seen</td><td style="border-bottom:1px solid #eeeeee;">{{.FirstSeen.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+{{- end}}
+{{- range .Context}}
+<tr><td style="font-weight:bold;border-bottom:1px solid #eeeeee;">{{.Key}}</td><td style="border-bottom:1px solid #eeeeee;">{{.Value}}</td></tr>
+{{- end}}
+</table>
+</td></tr>
+<tr><td style="padding:16px 24px 24px 24px;">
+<pre style="margin:0;padding:12px;background-color:#f8f8f8;border:1px solid #dddddd;font-family:Consolas,Menlo,monospace;font-size:12px;white-space:pre-wrap;word-break:break-all;">{{.Error}}</pre>
+</td></tr>
+</table>
+</td></tr>
+</table>
+</body>
+</html>
+`
+
+var defaultEmailTmpl = template.Must(template.New("email").Parse(defaultEmailTemplate))
+
+func getTemplateFiles() []string {
+	files := os.Getenv("EMAIL_TEMPLATE_FILES")
+	if len(files) == 0 {
+		return nil
+	}
+	templateFiles := []string{}
+	for _, f := range strings.Split(files, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			templateFiles = append(templateFiles, f)
+		}
+	}
+	return templateFiles
+}
+
+// newEmailTemplateData fills template data from env and the alerted error
+func newEmailTemplateData(subject string, errMsg string) EmailTemplateData {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "hostname_unknown"
+	}
+	return EmailTemplateData{
+		Subject:         subject,
+		Hostname:        hostname,
+		AppName:         os.Getenv("APP_NAME"),
+		AppEnv:          os.Getenv("APP_ENV"),
+		Time:            time.Now(),
+		OccurrenceCount: 1,
+		Error:           errMsg,
+	}
+}
+
+// renderBody executes the custom template files or the default layout. Values are HTML escaped
+func (ec *EmailConfig) renderBody(data EmailTemplateData) (string, error) {
+	tmpl := defaultEmailTmpl
+	if len(ec.TemplateFiles) != 0 {
+		// first file is the entry point, others can hold partials
+		t, err := template.New(filepath.Base(ec.TemplateFiles[0])).ParseFiles(ec.TemplateFiles...)
+		if err != nil {
+			return "", err
+		}
+		tmpl = t
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
@@ -0,0 +1,153 @@
+package alertnotification
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmailConfig_renderBody(t *testing.T) {
+	dir := t.TempDir()
+	customFile := filepath.Join(dir, "custom.html")
+	if err := os.WriteFile(customFile, []byte(`<p>{{.AppName}}: {{template "error" .}}</p>`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	partialFile := filepath.Join(dir, "partial.html")
+	if err := os.WriteFile(partialFile, []byte(`{{define "error"}}<code>{{.Error}}</code>{{end}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data := EmailTemplateData{
+		Subject:         "Alert",
+		AppName:         "golang",
+		OccurrenceCount: 1,
+		Context:         []ContextField{{Key: "request_id", Value: "req-<42>"}},
+		Error:           `<script>alert("x")</script>`,
+	}
+
+	tests := []struct {
+		name          string
+		templateFiles []string
+		want          []string
+		wantErr       bool
+	}{
+		{
+			name: "default",
+			want: []string{"<pre", "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;", "golang", "request_id", "req-&lt;42&gt;"},
+		},
+		{
+			name:          "custom_files",
+			templateFiles: []string{customFile, partialFile},
+			want:          []string{`<p>golang: <code>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</code></p>`},
+		},
+		{
+			name:          "missing_file",
+			templateFiles: []string{filepath.Join(dir, "missing.html")},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec := &EmailConfig{TemplateFiles: tt.templateFiles}
+			got, err := ec.renderBody(data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EmailConfig.renderBody() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Contains(got, "<script>") {
+				t.Errorf("EmailConfig.renderBody() did not escape error: %v", got)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("EmailConfig.renderBody() = %v, want to contain %v", got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestEmailConfig_Send(t *testing.T) {
+	s := newFakeSMTPServer(t)
+	host, port := s.hostPort()
+	ec := &EmailConfig{
+		Host:      host,
+		Port:      port,
+		Sender:    "test@example.com",
+		Receivers: []string{"receiver.test@example.com"},
+		Subject:   "Alert",
+		ErrorObj:  errors.New("<b>broken</b>"),
+		Pool:      NewSMTPPool(0, 0),
+	}
+	if err := ec.Send(); err != nil {
+		t.Fatalf("EmailConfig.Send() error = %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) != 1 {
+		t.Fatalf("EmailConfig.Send() delivered %v messages, want 1", len(s.messages))
+	}
+	parts := strings.SplitN(s.messages[0], "\r\n\r\n", 2)
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(parts[1], "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "&lt;b&gt;broken&lt;/b&gt;") {
+		t.Errorf("EmailConfig.Send() body = %v, want escaped error", string(body))
+	}
+}
+
+func TestEmailConfig_Send_attachFullError(t *testing.T) {
+	s := newFakeSMTPServer(t)
+	host, port := s.hostPort()
+	fullError := "first line\n" + strings.Repeat("frame\n", 1000) + "last line"
+	ec := &EmailConfig{
+		Host:            host,
+		Port:            port,
+		Sender:          "test@example.com",
+		Receivers:       []string{"receiver.test@example.com"},
+		Subject:         "Alert",
+		ErrorObj:        errors.New(fullError),
+		Pool:            NewSMTPPool(0, 0),
+		MaxErrorBytes:   200,
+		AttachFullError: true,
+	}
+	if err := ec.Send(); err != nil {
+		t.Fatalf("EmailConfig.Send() error = %v", err)
+	}
+	s.mu.Lock()
+	raw := s.messages[0]
+	s.mu.Unlock()
+
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %v, %v, want multipart/mixed", mediaType, err)
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	parts := []string{}
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		b, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		parts = append(parts, string(b))
+	}
+	if len(parts) != 2 {
+		t.Fatalf("message has %v parts, want 2", len(parts))
+	}
+	if !strings.Contains(parts[0], "lines elided") || strings.Contains(parts[0], "last line\n"+strings.Repeat("frame", 2)) {
+		t.Errorf("body does not contain the shortened error: %v", parts[0])
+	}
+	if parts[1] != fullError {
+		t.Errorf("attachment = %q, want the full error", parts[1])
+	}
+}
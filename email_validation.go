@@ -0,0 +1,100 @@
+package alertnotification
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+)
+
+var (
+	// ErrEmptyReceivers is returned when no receiver is configured
+	ErrEmptyReceivers = errors.New("notification receivers are empty")
+	// ErrInvalidAddress is returned when an address cannot be parsed by net/mail
+	ErrInvalidAddress = errors.New("invalid email address")
+	// ErrHeaderInjection is returned when an address carries CR or LF
+	ErrHeaderInjection = errors.New("header value contains line break")
+)
+
+// EmailValidationError describes which field of EmailConfig failed validation
+type EmailValidationError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *EmailValidationError) Error() string {
+	return fmt.Sprintf("email %s %q: %v", e.Field, e.Value, e.Err)
+}
+
+// Unwrap makes errors.Is work with the sentinel errors
+func (e *EmailValidationError) Unwrap() error {
+	return e.Err
+}
+
+// emailAddresses holds the parsed addresses used to build the message and the SMTP envelope
+type emailAddresses struct {
+	From         *mail.Address
+	EnvelopeFrom string
+	To           []*mail.Address
+}
+
+// Validate checks all addresses of the config. It does not touch the network
+func (ec *EmailConfig) Validate() error {
+	_, err := ec.parseAddresses()
+	return err
+}
+
+func (ec *EmailConfig) parseAddresses() (*emailAddresses, error) {
+	if len(ec.Receivers) == 0 {
+		return nil, &EmailValidationError{Field: "Receivers", Err: ErrEmptyReceivers}
+	}
+	from, err := parseAddress("Sender", ec.Sender)
+	if err != nil {
+		return nil, err
+	}
+	envelopeFrom := from
+	if len(strings.TrimSpace(ec.EnvelopeFrom)) != 0 {
+		if envelopeFrom, err = parseAddress("EnvelopeFrom", ec.EnvelopeFrom); err != nil {
+			return nil, err
+		}
+	}
+	addresses := &emailAddresses{
+		From:         from,
+		EnvelopeFrom: envelopeFrom.Address,
+	}
+	for _, r := range ec.Receivers {
+		to, err := parseAddress("Receivers", r)
+		if err != nil {
+			return nil, err
+		}
+		addresses.To = append(addresses.To, to)
+	}
+	return addresses, nil
+}
+
+func parseAddress(field string, value string) (*mail.Address, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return nil, &EmailValidationError{Field: field, Value: value, Err: ErrHeaderInjection}
+	}
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return nil, &EmailValidationError{Field: field, Value: value, Err: fmt.Errorf("%w: %v", ErrInvalidAddress, err)}
+	}
+	return address, nil
+}
+
+// encodeHeader folds line breaks into spaces and MIME encodes non ASCII text
+func encodeHeader(value string) string {
+	value = strings.Join(strings.Fields(strings.NewReplacer("\r", " ", "\n", " ").Replace(value)), " ")
+	return mime.QEncoding.Encode("UTF-8", value)
+}
+
+func joinAddresses(addresses []*mail.Address) string {
+	formatted := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		formatted = append(formatted, a.String())
+	}
+	return strings.Join(formatted, ", ")
+}
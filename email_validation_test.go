@@ -0,0 +1,93 @@
+package alertnotification
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEmailConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    EmailConfig
+		wantErr   error
+		wantField string
+	}{
+		{
+			name: "valid",
+			config: EmailConfig{
+				Sender:    "Alert <test@example.com>",
+				Receivers: []string{"receiver.test@example.com", " other@example.com"},
+			},
+		},
+		{
+			name: "empty_receivers",
+			config: EmailConfig{
+				Sender: "test@example.com",
+			},
+			wantErr:   ErrEmptyReceivers,
+			wantField: "Receivers",
+		},
+		{
+			name: "invalid_sender",
+			config: EmailConfig{
+				Sender:    "not an address",
+				Receivers: []string{"receiver.test@example.com"},
+			},
+			wantErr:   ErrInvalidAddress,
+			wantField: "Sender",
+		},
+		{
+			name: "injected_receiver",
+			config: EmailConfig{
+				Sender:    "test@example.com",
+				Receivers: []string{"receiver.test@example.com\r\nBcc: evil@example.com"},
+			},
+			wantErr:   ErrHeaderInjection,
+			wantField: "Receivers",
+		},
+		{
+			name: "injected_envelope_from",
+			config: EmailConfig{
+				Sender:       "test@example.com",
+				EnvelopeFrom: "bounce@example.com\nRCPT TO:<evil@example.com>",
+				Receivers:    []string{"receiver.test@example.com"},
+			},
+			wantErr:   ErrHeaderInjection,
+			wantField: "EnvelopeFrom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("EmailConfig.Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			var validationErr *EmailValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+				t.Errorf("EmailConfig.Validate() error = %#v, want field %v", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func Test_encodeHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "Alert subject", want: "Alert subject"},
+		{name: "line_breaks", value: "Alert\r\nBcc: evil@example.com", want: "Alert Bcc: evil@example.com"},
+		{name: "non_ascii", value: "エラー", want: "=?UTF-8?q?=E3=82=A8=E3=83=A9=E3=83=BC?="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeHeader(tt.value); got != tt.want {
+				t.Errorf("encodeHeader() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
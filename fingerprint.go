@@ -0,0 +1,66 @@
+package alertnotification
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sync"
+)
+
+// FingerprintFunc returns the text identifying an error, occurrences with the same text are the same alert
+type FingerprintFunc func(err error) string
+
+var (
+	fingerprintMu   sync.RWMutex
+	fingerprintFunc FingerprintFunc = func(err error) string { return NormalizeError(err.Error()) }
+)
+
+// normalizers replace the variable parts of error messages, in order
+var normalizers = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}{
+	{pattern: regexp.MustCompile(`"(?:[^"\\]|\\.)*"|` + "`[^`]*`"), placeholder: "<quoted>"},
+	// an apostrophe after a letter is a contraction or a possessive, eg. "can't", not a quote
+	{pattern: regexp.MustCompile(`(^|[^\w])'(?:[^'\\]|\\.)*'`), placeholder: "${1}<quoted>"},
+	{pattern: regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), placeholder: "<uuid>"},
+	{pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?\b`), placeholder: "<ip>"},
+	{pattern: regexp.MustCompile(`(?i)\[[0-9a-f:]+\](?::\d+)?|(?:\b[0-9a-f]{1,4}(?::[0-9a-f]{1,4})*)?::[0-9a-f]{1,4}(?::[0-9a-f]{1,4})*\b|\b[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){7}\b`), placeholder: "<ip>"},
+	{pattern: regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]*[0-9][0-9a-f]*[a-f][0-9a-f]*\b|\b[0-9a-f]*[a-f][0-9a-f]*[0-9][0-9a-f]*\b`), placeholder: "<hex>"},
+	{pattern: regexp.MustCompile(`\d+(?:\.\d+)?`), placeholder: "<n>"},
+}
+
+// NormalizeError replaces quoted values, UUIDs, IPs, hex IDs and numbers of message by placeholders,
+// eg. "timeout after 1532ms" becomes "timeout after <n>ms"
+func NormalizeError(message string) string {
+	for _, n := range normalizers {
+		message = n.pattern.ReplaceAllString(message, n.placeholder)
+	}
+	return message
+}
+
+// SetFingerprintFunc replaces NormalizeError to group errors into alerts, nil restores it
+func SetFingerprintFunc(f FingerprintFunc) {
+	fingerprintMu.Lock()
+	defer fingerprintMu.Unlock()
+	if f == nil {
+		f = func(err error) string { return NormalizeError(err.Error()) }
+	}
+	fingerprintFunc = f
+}
+
+// Fingerprint is the hashed identity of err, used as throttling key
+func Fingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+	fingerprintMu.RLock()
+	f := fingerprintFunc
+	fingerprintMu.RUnlock()
+	return hashFingerprint(f(err))
+}
+
+func hashFingerprint(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package alertnotification
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{message: "timeout after 1532ms", want: "timeout after <n>ms"},
+		{message: `user "bob" not found in 'users'`, want: "user <quoted> not found in <quoted>"},
+		{message: "request 3f2a1c9e-1b2c-4d5e-8f90-123456789abc failed", want: "request <uuid> failed"},
+		{message: "dial tcp 10.0.0.12:5432: connection refused", want: "dial tcp <ip>: connection refused"},
+		{message: "dial tcp [2001:db8::1]:443 and fe80::1:2", want: "dial tcp <ip> and <ip>"},
+		{message: "object deadbeef01 missing at 0x7ffe12", want: "object <hex> missing at <hex>"},
+		{message: "std::io::Error at 12:30:45", want: "std::io::Error at <n>:<n>:<n>"},
+		{message: "cafe face bad", want: "cafe face bad"},
+		{message: "can't open config file, won't retry", want: "can't open config file, won't retry"},
+		{message: "order's total doesn't match 'EUR'", want: "order's total doesn't match <quoted>"},
+		{message: "'users' isn't ready", want: "<quoted> isn't ready"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeError(tt.message); got != tt.want {
+			t.Errorf("NormalizeError(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	first := Fingerprint(errors.New("timeout after 1532ms"))
+	if first != Fingerprint(errors.New("timeout after 1533ms")) {
+		t.Errorf("Fingerprint() differs for errors differing by a number")
+	}
+	if first == Fingerprint(errors.New("connection refused")) {
+		t.Errorf("Fingerprint() is the same for different errors")
+	}
+	contractions := []string{
+		"can't open config file, won't retry",
+		"can't reach payment gateway, won't retry",
+		"order's total doesn't match",
+	}
+	for i, a := range contractions {
+		for _, b := range contractions[i+1:] {
+			if Fingerprint(errors.New(a)) == Fingerprint(errors.New(b)) {
+				t.Errorf("Fingerprint() is the same for %q and %q", a, b)
+			}
+		}
+	}
+	if len(first) != 64 || strings.Trim(first, "0123456789abcdef") != "" {
+		t.Errorf("Fingerprint() = %v, want a sha256 hex", first)
+	}
+
+	SetFingerprintFunc(func(err error) string { return strings.SplitN(err.Error(), ":", 2)[0] })
+	defer SetFingerprintFunc(nil)
+	if Fingerprint(errors.New("db: timeout")) != Fingerprint(errors.New("db: connection refused")) {
+		t.Errorf("Fingerprint() did not use the custom function")
+	}
+}
+
+func TestAlert_fingerprint(t *testing.T) {
+	a := &Alert{Error: errors.New("order 42 failed"), Fingerprint: "orders"}
+	b := &Alert{Error: errors.New("payment 7 failed"), Fingerprint: "orders"}
+	if a.fingerprint() != b.fingerprint() {
+		t.Errorf("Alert.fingerprint() differs for the same Fingerprint key")
+	}
+	c := &Alert{Error: errors.New("order 43 failed")}
+	if c.fingerprint() != Fingerprint(errors.New("order 42 failed")) {
+		t.Errorf("Alert.fingerprint() without key is not the error fingerprint")
+	}
+
+	d := &Alert{Error: errors.New("order 43 failed"), SuppressionRules: []SuppressionRule{SuppressFingerprint(errors.New("order 1 failed"))}}
+	if !d.isDoNotAlert() {
+		t.Errorf("Alert.isDoNotAlert() = false for an error with the fingerprint of SuppressFingerprint")
+	}
+	e := &Alert{Error: errors.New("can't reach payment gateway, won't retry"), SuppressionRules: []SuppressionRule{SuppressFingerprint(errors.New("can't open config file, won't retry"))}}
+	if e.isDoNotAlert() {
+		t.Errorf("Alert.isDoNotAlert() = true for a different error with contractions")
+	}
+	f := &Alert{Error: errors.New("unexpected status 500"), DoNotAlertErrors: []error{errors.New("unexpected status 404")}}
+	if f.isDoNotAlert() {
+		t.Errorf("Alert.isDoNotAlert() = true, DoNotAlertErrors must match the message exactly")
+	}
+}
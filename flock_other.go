@@ -0,0 +1,14 @@
+//go:build !unix
+
+package alertnotification
+
+import "os"
+
+// lockFile is a no-op without flock, CompareAndSwap of DiskStore is then atomic within the process only
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package alertnotification
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile waits for an exclusive lock of f, shared with the other processes
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
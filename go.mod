@@ -4,7 +4,20 @@ go 1.21
 
 require (
 	github.com/GitbookIO/diskache v0.0.0-20161028144708-bfb81bf58cb1
+	github.com/alicebob/miniredis/v2 v2.34.0
+	github.com/emersion/go-msgauth v0.7.0
 	github.com/joho/godotenv v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/redis/go-redis/v9 v9.7.3
+	golang.org/x/net v0.33.0
 )
 
-require github.com/GitbookIO/syncgroup v0.0.0-20200915204659-4f0b2961ab10 // indirect
+require (
+	github.com/GitbookIO/syncgroup v0.0.0-20200915204659-4f0b2961ab10 // indirect
+	github.com/alicebob/gopher-json v0.0.0-20230218143504-906a9b012302 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
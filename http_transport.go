@@ -0,0 +1,144 @@
+package alertnotification
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var (
+	httpClientMu    sync.Mutex
+	userHTTPClient  *http.Client
+	sharedTransport *http.Transport
+)
+
+// HTTPTransportConfig is the connection setup shared by the webhook notifiers
+type HTTPTransportConfig struct {
+	ProxyURL       string // proxies from HTTP_PROXY/HTTPS_PROXY when empty
+	ProxyUsername  string // basic auth, overrides the user info of the proxy
+	ProxyPassword  string
+	NoProxy        string // comma separated hosts, domains, IPs or CIDRs reached directly
+	CAFile         string // PEM certificates trusted in addition to the system ones
+	ClientCertFile string // PEM client certificate for mTLS
+	ClientKeyFile  string
+	MaxIdleConns   int // idle connections kept per host
+}
+
+// NewHTTPTransportConfig reads the transport config from env
+func NewHTTPTransportConfig() HTTPTransportConfig {
+	c := HTTPTransportConfig{
+		ProxyURL:       os.Getenv("HTTP_PROXY_URL"),
+		ProxyUsername:  os.Getenv("HTTP_PROXY_USERNAME"),
+		ProxyPassword:  os.Getenv("HTTP_PROXY_PASSWORD"),
+		NoProxy:        os.Getenv("HTTP_NO_PROXY"),
+		CAFile:         os.Getenv("HTTP_CA_FILE"),
+		ClientCertFile: os.Getenv("HTTP_CLIENT_CERT_FILE"),
+		ClientKeyFile:  os.Getenv("HTTP_CLIENT_KEY_FILE"),
+		MaxIdleConns:   getEnvInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 4),
+	}
+	if c.ProxyURL == "" {
+		c.ProxyURL = os.Getenv("MS_TEAMS_PROXY_URL")
+	}
+	if c.NoProxy == "" {
+		c.NoProxy = os.Getenv("NO_PROXY")
+	}
+	if c.NoProxy == "" {
+		c.NoProxy = os.Getenv("no_proxy")
+	}
+	return c
+}
+
+// Transport builds the http.Transport of the config
+func (c HTTPTransportConfig) Transport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = c.MaxIdleConns
+
+	proxy, err := c.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	t.Proxy = proxy
+
+	if c.CAFile == "" && c.ClientCertFile == "" {
+		return t, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// proxyFunc resolves the proxy of requests with ProxyURL, or HTTP_PROXY/HTTPS_PROXY, and NoProxy
+func (c HTTPTransportConfig) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	config := httpproxy.FromEnvironment()
+	config.NoProxy = c.NoProxy
+	if c.ProxyURL != "" {
+		if _, err := url.Parse(c.ProxyURL); err != nil {
+			return nil, err
+		}
+		config.HTTPProxy = c.ProxyURL
+		config.HTTPSProxy = c.ProxyURL
+	}
+	proxyURL := config.ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		proxy, err := proxyURL(r.URL)
+		if err != nil || proxy == nil || c.ProxyUsername == "" {
+			return proxy, err
+		}
+		withUser := *proxy
+		withUser.User = url.UserPassword(c.ProxyUsername, c.ProxyPassword)
+		return &withUser, nil
+	}, nil
+}
+
+// SetHTTPClient makes all webhook notifiers use client, nil restores the shared client built from env.
+// Timeouts of the client are kept as is
+func SetHTTPClient(client *http.Client) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	userHTTPClient = client
+}
+
+// httpClient returns the client set with SetHTTPClient or a client on the shared transport
+// with the {prefix}_TIMEOUT_SECONDS timeout
+func httpClient(prefix string) (*http.Client, error) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	if userHTTPClient != nil {
+		return userHTTPClient, nil
+	}
+	if sharedTransport == nil {
+		t, err := NewHTTPTransportConfig().Transport()
+		if err != nil {
+			return nil, err
+		}
+		sharedTransport = t
+	}
+	return &http.Client{Transport: sharedTransport, Timeout: getWebhookTimeout(prefix)}, nil
+}
@@ -0,0 +1,292 @@
+package alertnotification
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	graphTokensMu sync.Mutex
+	graphTokens   = map[string]graphToken{}
+)
+
+// MsGraphTeams posts alerts to a Teams channel through Microsoft Graph.
+// The first occurrence of an error starts a thread, recurrences reply in it and Resolve updates the first card
+type MsGraphTeams struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string // delegated token of a service account, client credentials are used when empty
+	TeamID       string
+	ChannelID    string
+	BaseURL      string // Graph API root, can point to a local fake
+	TokenURL     string // OAuth2 token endpoint, can point to a local fake
+	Card         MsTeam
+	ThreadKey    string        // identifies the thread of the error
+	ThreadTTL    time.Duration // a thread without new occurrence for this long is forgotten
+}
+
+// graphDelegatedScope are the delegated permissions needed to post and edit channel messages
+const graphDelegatedScope = "https://graph.microsoft.com/ChannelMessage.Send https://graph.microsoft.com/ChannelMessage.ReadWrite offline_access"
+
+type graphToken struct {
+	AccessToken  string
+	RefreshToken string // latest refresh token, Azure AD rotates it on each use
+	ExpiresAt    time.Time
+}
+
+type graphMessage struct {
+	Body        graphItemBody     `json:"body"`
+	Attachments []graphAttachment `json:"attachments"`
+}
+
+type graphItemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type graphAttachment struct {
+	ID          string `json:"id"`
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"` // the card serialized as a JSON string
+}
+
+// NewMsGraphTeams creates MsGraphTeams from env
+func NewMsGraphTeams(err error, expandos *Expandos) MsGraphTeams {
+	return newAlertMsGraphTeams(&Alert{Error: err, Expandos: expandos})
+}
+
+func newAlertMsGraphTeams(a *Alert) MsGraphTeams {
+	return newMsGraphTeams(newAlertMsTeam(a), a.fingerprint())
+}
+
+// newMsGraphTeams creates the Graph notification of card, replying in the thread of threadKey
+func newMsGraphTeams(card MsTeam, threadKey string) MsGraphTeams {
+	g := MsGraphTeams{
+		TenantID:     os.Getenv("MS_GRAPH_TENANT_ID"),
+		ClientID:     os.Getenv("MS_GRAPH_CLIENT_ID"),
+		ClientSecret: os.Getenv("MS_GRAPH_CLIENT_SECRET"),
+		RefreshToken: os.Getenv("MS_GRAPH_REFRESH_TOKEN"),
+		TeamID:       os.Getenv("MS_GRAPH_TEAM_ID"),
+		ChannelID:    os.Getenv("MS_GRAPH_CHANNEL_ID"),
+		BaseURL:      os.Getenv("MS_GRAPH_BASE_URL"),
+		TokenURL:     os.Getenv("MS_GRAPH_TOKEN_URL"),
+		Card:         card,
+		ThreadKey:    threadKey,
+	}
+	g.ThreadTTL = 7 * 24 * time.Hour
+	if len(os.Getenv("MS_GRAPH_THREAD_TTL")) != 0 {
+		if ttl, err := parseDuration(os.Getenv("MS_GRAPH_THREAD_TTL"), time.Hour); err == nil {
+			g.ThreadTTL = ttl
+		}
+	}
+	if g.BaseURL == "" {
+		g.BaseURL = "https://graph.microsoft.com/v1.0"
+	}
+	if g.TokenURL == "" {
+		g.TokenURL = "https://login.microsoftonline.com/" + url.PathEscape(g.TenantID) + "/oauth2/v2.0/token"
+	}
+	return g
+}
+
+// Send posts the card as a new message, or as a reply when the error already has a thread
+func (g *MsGraphTeams) Send() error {
+	messageID, err := g.threadMessageID()
+	if err != nil {
+		return err
+	}
+	messagesURL := g.messagesURL()
+	if messageID != "" {
+		_, err = g.request(http.MethodPost, messagesURL+"/"+url.PathEscape(messageID)+"/replies", newRetryPolicy("MS_GRAPH"))
+		if err == nil {
+			// keep the thread while the error recurs
+			return g.saveThreadMessageID(messageID)
+		}
+		if !isNotFound(err) {
+			return err
+		}
+		// the first message was deleted from the channel, the error starts a new thread
+		if err := g.saveThreadMessageID(""); err != nil {
+			return err
+		}
+	}
+
+	// a retry after a timeout or a 5xx could start a second thread, the next occurrence starts it instead
+	policy := newRetryPolicy("MS_GRAPH")
+	policy.MaxRetries = 0
+	body, err := g.request(http.MethodPost, messagesURL, policy)
+	if err != nil {
+		return err
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil || created.ID == "" {
+		return errors.New("cannot read the id of the message created in Teams")
+	}
+	return g.saveThreadMessageID(created.ID)
+}
+
+// Resolve replaces the first card of the thread by the current card and forgets the thread
+func (g *MsGraphTeams) Resolve() error {
+	messageID, err := g.threadMessageID()
+	if err != nil || messageID == "" {
+		return err
+	}
+	if _, err := g.request(http.MethodPatch, g.messagesURL()+"/"+url.PathEscape(messageID), newRetryPolicy("MS_GRAPH")); err != nil {
+		return err
+	}
+	return g.saveThreadMessageID("")
+}
+
+// isNotFound tells if err is a 404 response of Graph
+func isNotFound(err error) bool {
+	var webhookErr *WebhookError
+	return errors.As(err, &webhookErr) && webhookErr.StatusCode == http.StatusNotFound
+}
+
+func (g *MsGraphTeams) messagesURL() string {
+	return strings.TrimSuffix(g.BaseURL, "/") + "/teams/" + url.PathEscape(g.TeamID) + "/channels/" + url.PathEscape(g.ChannelID) + "/messages"
+}
+
+// request sends the card to Graph, retried with policy, and returns the response body
+func (g *MsGraphTeams) request(method string, requestURL string, policy retryPolicy) ([]byte, error) {
+	if g.Card.err != nil {
+		return nil, g.Card.err
+	}
+	token, err := g.token()
+	if err != nil {
+		return nil, err
+	}
+	card, err := json.Marshal(g.Card.Attachments[0].Content)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(graphMessage{
+		Body: graphItemBody{ContentType: "html", Content: `<attachment id="alert"></attachment>`},
+		Attachments: []graphAttachment{{
+			ID:          "alert",
+			ContentType: g.Card.Attachments[0].ContentType,
+			Content:     string(card),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := httpClient("MS_GRAPH")
+	if err != nil {
+		return nil, err
+	}
+	return sendWebhookRequest(client, webhookRequest{
+		Method: method,
+		URL:    requestURL,
+		Body:   payload,
+		Header: http.Header{"Authorization": {"Bearer " + token}},
+		Accepted: func(statusCode int) bool {
+			return statusCode >= 200 && statusCode < 300
+		},
+		Describe: describeMicrosoftError,
+	}, policy)
+}
+
+// token returns a cached client credentials token, renewed one minute before it expires
+func (g *MsGraphTeams) token() (string, error) {
+	cacheKey := g.TokenURL + "|" + g.ClientID
+	if g.RefreshToken != "" {
+		cacheKey += "|" + hashFingerprint(g.RefreshToken)
+	}
+	graphTokensMu.Lock()
+	cached, ok := graphTokens[cacheKey]
+	graphTokensMu.Unlock()
+	if ok && time.Now().Before(cached.ExpiresAt) {
+		return cached.AccessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {g.ClientID},
+		"client_secret": {g.ClientSecret},
+		"scope":         {"https://graph.microsoft.com/.default"},
+	}
+	if g.RefreshToken != "" {
+		// app-only tokens can only post in migration mode, post as the user of the refresh token
+		refreshToken := g.RefreshToken
+		if cached.RefreshToken != "" {
+			refreshToken = cached.RefreshToken
+		}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+		form.Set("scope", graphDelegatedScope)
+		if g.ClientSecret == "" {
+			form.Del("client_secret")
+		}
+	}
+	client, err := httpClient("MS_GRAPH")
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.PostForm(g.TokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+		Error        string `json:"error"`
+		Description  string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", errors.New("cannot get Microsoft Graph token: " + token.Error + " " + token.Description)
+	}
+
+	graphTokensMu.Lock()
+	if token.RefreshToken == "" {
+		token.RefreshToken = cached.RefreshToken
+	}
+	graphTokens[cacheKey] = graphToken{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute),
+	}
+	graphTokensMu.Unlock()
+	return token.AccessToken, nil
+}
+
+// threadCacheKey is where the id of the first message of the error is kept, in the throttle store
+func (g *MsGraphTeams) threadCacheKey() string {
+	return "graph_thread_" + g.TeamID + "_" + g.ChannelID + "_" + g.ThreadKey
+}
+
+func (g *MsGraphTeams) threadMessageID() (string, error) {
+	t := NewThrottler()
+	store, err := t.store()
+	if err != nil {
+		return "", err
+	}
+	messageID, _, err := store.Get(g.threadCacheKey())
+	return string(messageID), err
+}
+
+func (g *MsGraphTeams) saveThreadMessageID(messageID string) error {
+	t := NewThrottler()
+	store, err := t.store()
+	if err != nil {
+		return err
+	}
+	if messageID == "" {
+		return store.Delete(g.threadCacheKey())
+	}
+	return store.Set(g.threadCacheKey(), []byte(messageID), g.ThreadTTL)
+}
@@ -0,0 +1,284 @@
+package alertnotification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type graphRequest struct {
+	Method  string
+	Path    string
+	Message graphMessage
+}
+
+// newFakeGraph serves a token endpoint and the channel messages API
+func newFakeGraph(t *testing.T) (*httptest.Server, func() []graphRequest) {
+	var mu sync.Mutex
+	requests := []graphRequest{}
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client","error_description":"bad secret"}`))
+				return
+			}
+			mu.Lock()
+			tokens++
+			mu.Unlock()
+			w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":"InvalidAuthenticationToken","message":"Access token is empty."}}`))
+			return
+		}
+		if strings.Contains(r.URL.Path, "/deleted/") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"NotFound","message":"Message not found."}}`))
+			return
+		}
+		var message graphMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("graph received invalid json: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, graphRequest{Method: r.Method, Path: r.URL.Path, Message: message})
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"1700000000000"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []graphRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		if tokens != 1 {
+			t.Errorf("graph issued %v tokens, want 1 cached token", tokens)
+		}
+		return append([]graphRequest{}, requests...)
+	}
+}
+
+// setGraphEnv enables Graph notifications to server
+func setGraphEnv(t *testing.T, server *httptest.Server) {
+	t.Setenv("THROTTLE_DISKCACHE_DIR", t.TempDir())
+	t.Setenv("MS_GRAPH_ALERT_ENABLED", "true")
+	t.Setenv("MS_GRAPH_CLIENT_ID", "client")
+	t.Setenv("MS_GRAPH_CLIENT_SECRET", "secret")
+	t.Setenv("MS_GRAPH_TEAM_ID", "team")
+	t.Setenv("MS_GRAPH_CHANNEL_ID", "channel")
+	t.Setenv("MS_GRAPH_BASE_URL", server.URL)
+	t.Setenv("MS_GRAPH_TOKEN_URL", server.URL+"/token")
+}
+
+func TestMsGraphTeams_thread(t *testing.T) {
+	server, requests := newFakeGraph(t)
+	setGraphEnv(t, server)
+
+	a := &Alert{Error: errors.New("graph error")}
+	for i := 0; i < 2; i++ {
+		g := newAlertMsGraphTeams(a)
+		if err := g.Send(); err != nil {
+			t.Fatalf("MsGraphTeams.Send() error = %v", err)
+		}
+	}
+	if err := a.Resolve(); err != nil {
+		t.Fatalf("Alert.Resolve() error = %v", err)
+	}
+	g := newAlertMsGraphTeams(a)
+	if err := g.Send(); err != nil {
+		t.Fatalf("MsGraphTeams.Send() error = %v", err)
+	}
+
+	messages := "/teams/team/channels/channel/messages"
+	want := []graphRequest{
+		{Method: http.MethodPost, Path: messages},
+		{Method: http.MethodPost, Path: messages + "/1700000000000/replies"},
+		{Method: http.MethodPatch, Path: messages + "/1700000000000"},
+		{Method: http.MethodPost, Path: messages},
+	}
+	got := requests()
+	if len(got) != len(want) {
+		t.Fatalf("graph received %v requests, want %v", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Method != want[i].Method || got[i].Path != want[i].Path {
+			t.Errorf("request %v = %v %v, want %v %v", i, got[i].Method, got[i].Path, want[i].Method, want[i].Path)
+		}
+		attachments := got[i].Message.Attachments
+		if len(attachments) != 1 || !strings.Contains(got[i].Message.Body.Content, `<attachment id="`+attachments[0].ID+`">`) {
+			t.Errorf("request %v does not reference its card: %+v", i, got[i].Message)
+		}
+	}
+	if card := got[2].Message.Attachments[0].Content; !strings.Contains(card, "Resolved: ") {
+		t.Errorf("resolved card = %v, want resolved title", card)
+	}
+}
+
+func TestAlert_Notify_autoResolve(t *testing.T) {
+	server, requests := newFakeGraph(t)
+	setGraphEnv(t, server)
+	t.Setenv("EMAIL_ALERT_ENABLED", "")
+	t.Setenv("MS_TEAMS_ALERT_ENABLED", "")
+	t.Setenv("THROTTLE_ENABLED", "")
+	t.Setenv("THROTTLE_DURATION", "200ms")
+	t.Setenv("THROTTLE_GRACE_SECONDS", "0")
+	patched := func() bool {
+		for _, r := range requests() {
+			if r.Method == http.MethodPatch {
+				return true
+			}
+		}
+		return false
+	}
+
+	a := &Alert{Error: errors.New("graph error")}
+	if err := a.Notify(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(120 * time.Millisecond)
+	// throttled, it delays the resolution by 120ms
+	if err := a.Notify(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(130 * time.Millisecond)
+	if patched() {
+		t.Fatalf("Alert.Notify() resolved the thread 50ms after the throttle duration of the last occurrence started")
+	}
+	deadline := time.Now().Add(time.Second)
+	for !patched() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !patched() {
+		t.Fatalf("Alert.Notify() did not resolve the thread once the error stopped")
+	}
+	got := requests()
+	if len(got) != 2 || !strings.Contains(got[1].Message.Attachments[0].Content, "Resolved: ") {
+		t.Errorf("graph received %+v, want the thread then its resolution", got)
+	}
+}
+
+func TestMsGraphTeams_deletedThread(t *testing.T) {
+	server, requests := newFakeGraph(t)
+	setGraphEnv(t, server)
+
+	g := newAlertMsGraphTeams(&Alert{Error: errors.New("graph error")})
+	if err := g.saveThreadMessageID("deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Send(); err != nil {
+		t.Fatalf("MsGraphTeams.Send() error = %v", err)
+	}
+	got := requests()
+	if len(got) != 1 || got[0].Path != "/teams/team/channels/channel/messages" {
+		t.Errorf("graph received %+v, want a new thread", got)
+	}
+	if messageID, _ := g.threadMessageID(); messageID != "1700000000000" {
+		t.Errorf("MsGraphTeams thread = %q, want the new thread", messageID)
+	}
+}
+
+func TestMsGraphTeams_threadTTL(t *testing.T) {
+	server, _ := newFakeGraph(t)
+	setGraphEnv(t, server)
+	t.Setenv("MS_GRAPH_THREAD_TTL", "50ms")
+
+	g := newAlertMsGraphTeams(&Alert{Error: errors.New("graph error")})
+	if g.ThreadTTL != 50*time.Millisecond {
+		t.Fatalf("MsGraphTeams.ThreadTTL = %v, want 50ms", g.ThreadTTL)
+	}
+	if err := g.Send(); err != nil {
+		t.Fatalf("MsGraphTeams.Send() error = %v", err)
+	}
+	if messageID, _ := g.threadMessageID(); messageID == "" {
+		t.Fatalf("MsGraphTeams.Send() did not keep the thread")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if messageID, _ := g.threadMessageID(); messageID != "" {
+		t.Errorf("MsGraphTeams thread = %q after MS_GRAPH_THREAD_TTL, want none", messageID)
+	}
+}
+
+func TestMsGraphTeams_Send_noRetry(t *testing.T) {
+	var posts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+			return
+		}
+		posts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	setGraphEnv(t, server)
+	t.Setenv("MS_GRAPH_MAX_RETRIES", "3")
+	t.Setenv("MS_GRAPH_RETRY_MAX_WAIT_SECONDS", "0")
+
+	g := newAlertMsGraphTeams(&Alert{Error: errors.New("graph error")})
+	if err := g.Send(); err == nil {
+		t.Fatalf("MsGraphTeams.Send() error = nil, want the 503")
+	}
+	if posts.Load() != 1 {
+		t.Errorf("MsGraphTeams.Send() posted the new thread %v times, want 1", posts.Load())
+	}
+}
+
+func TestMsGraphTeams_tokenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_client","error_description":"bad secret"}`))
+	}))
+	defer server.Close()
+	t.Setenv("THROTTLE_DISKCACHE_DIR", t.TempDir())
+
+	g := MsGraphTeams{
+		ClientID: "other",
+		BaseURL:  server.URL,
+		TokenURL: server.URL + "/token",
+		Card:     NewMsTeam(errors.New("graph error"), nil),
+	}
+	err := g.Send()
+	if err == nil || !strings.Contains(err.Error(), "bad secret") {
+		t.Errorf("MsGraphTeams.Send() error = %v, want token error", err)
+	}
+}
+
+func TestMsGraphTeams_refreshToken(t *testing.T) {
+	var mu sync.Mutex
+	var refreshTokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "refresh_token" || !strings.Contains(r.FormValue("scope"), "ChannelMessage.Send") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		mu.Lock()
+		refreshTokens = append(refreshTokens, r.FormValue("refresh_token"))
+		rotated := fmt.Sprintf("rotated-%d", len(refreshTokens))
+		mu.Unlock()
+		// expires at once so each call asks for a new token
+		fmt.Fprintf(w, `{"access_token":"token","refresh_token":%q,"expires_in":0}`, rotated)
+	}))
+	defer server.Close()
+
+	g := MsGraphTeams{ClientID: "client", RefreshToken: "initial", TokenURL: server.URL}
+	for i := 0; i < 3; i++ {
+		if _, err := g.token(); err != nil {
+			t.Fatalf("MsGraphTeams.token() error = %v", err)
+		}
+	}
+	want := []string{"initial", "rotated-1", "rotated-2"}
+	if !reflect.DeepEqual(refreshTokens, want) {
+		t.Errorf("token endpoint received refresh tokens %v, want %v", refreshTokens, want)
+	}
+}
@@ -1,14 +1,12 @@
 package alertnotification
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -16,12 +14,14 @@ import (
 type MsTeam struct {
 	Type        string       `json:"type"`
 	Attachments []attachment `json:"attachments"`
+
+	err error // card template failure, returned by Send
 }
 
 type attachment struct {
 	ContentType string      `json:"contentType"`
 	ContentURL  *string     `json:"contentUrl"`
-	Content     cardContent `json:"content"`
+	Content     interface{} `json:"content"` // cardContent or the rendered json.RawMessage of a custom template
 }
 
 type cardContent struct {
@@ -41,6 +41,7 @@ type textBlock struct {
 	Size   string `json:"size,omitempty"`
 	Weight string `json:"weight,omitempty"`
 	Color  string `json:"color,omitempty"`
+	Wrap   bool   `json:"wrap,omitempty"`
 }
 
 type fact struct {
@@ -62,20 +63,106 @@ type codeBlock struct {
 }
 
 type action struct {
-	Type  string `json:"type"`
-	Title string `json:"title"`
-	URL   string `json:"url"`
+	Type  string      `json:"type"`
+	Title string      `json:"title"`
+	URL   string      `json:"url,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
 }
 
 type msTeams struct {
-	Width string `json:"width"`
+	Width    string          `json:"width"`
+	Entities []mentionEntity `json:"entities,omitempty"`
+}
+
+// severityColor is the default card colour of a severity
+type severityColor struct {
+	Accent string // hex colour of the card
+	Title  string // Adaptive Card named colour of the title
+}
+
+var severityColors = map[Severity]severityColor{
+	SeverityInfo:     {Accent: "0078d7", Title: "accent"},
+	SeverityWarning:  {Accent: "ffb900", Title: "warning"},
+	SeverityCritical: {Accent: "bf0000", Title: "attention"},
+	SeverityResolved: {Accent: "2eb886", Title: "good"},
+}
+
+// cardColors resolves card colours from expandos, ALERT_THEME_COLOR_{SEVERITY}, severity and ALERT_THEME_COLOR in this order
+func cardColors(severity Severity, expandos *Expandos) (accent string, title string) {
+	accent, title = "bf0000", "accent"
+	if c := os.Getenv("ALERT_THEME_COLOR"); c != "" {
+		accent = c
+	}
+	if c, ok := severityColors[severity]; ok {
+		accent, title = c.Accent, c.Title
+		if envColor := os.Getenv("ALERT_THEME_COLOR_" + strings.ToUpper(string(severity))); envColor != "" {
+			accent = envColor
+		}
+	}
+	if expandos != nil && expandos.MsTeamsThemeColor != "" {
+		accent = expandos.MsTeamsThemeColor
+	}
+	return strings.TrimPrefix(accent, "#"), title
 }
 
 // NewMsTeam is used to create MsTeam
 func NewMsTeam(err error, expandos *Expandos) MsTeam {
+	return newAlertMsTeam(&Alert{Error: err, Expandos: expandos})
+}
+
+// newAlertMsTeam creates the card of the alert. The error is shortened when the card exceeds MS_TEAMS_MAX_PAYLOAD_BYTES
+func newAlertMsTeam(a *Alert) MsTeam {
+	errMsg := fmt.Sprintf("%+v", a.Error)
+	if a.Expandos != nil && a.Expandos.MsTeamsError != "" {
+		errMsg = a.Expandos.MsTeamsError
+	}
+	card := buildMsTeam(a, errMsg)
+
+	limit := getMsTeamsMaxPayloadBytes()
+	// a few passes are enough, the error can appear several times in custom templates
+	for i := 0; i < 5 && limit > 0 && card.err == nil; i++ {
+		size, err := card.size()
+		if err != nil || size <= limit {
+			break
+		}
+		// JSON escaping makes the error bigger in the payload, shrink it in proportion
+		escaped, _ := json.Marshal(errMsg)
+		escapedLen := len(escaped) - 2
+		if escapedLen == 0 {
+			break
+		}
+		budget := len(errMsg)*(escapedLen-(size-limit))/escapedLen - len(elisionMarker(0)) - 32
+		errMsg = truncateMiddle(errMsg, budget)
+		card = buildMsTeam(a, errMsg)
+	}
+	return card
+}
+
+// getMsTeamsMaxPayloadBytes is the card size budget, Teams rejects messages over about 28KB
+func getMsTeamsMaxPayloadBytes() int {
+	return getEnvInt("MS_TEAMS_MAX_PAYLOAD_BYTES", 28000) // default 28KB
+}
+
+// size is the length of the JSON sent to the webhook
+func (card *MsTeam) size() (int, error) {
+	b, err := json.Marshal(card)
+	return len(b), err
+}
+
+// cardTitle marks the title of resolved alerts
+func cardTitle(title string, severity Severity) string {
+	if severity == SeverityResolved {
+		return "Resolved: " + title
+	}
+	return title
+}
+
+// buildMsTeam creates the card with the given error text
+func buildMsTeam(a *Alert, errMsg string) MsTeam {
+	expandos := a.Expandos
+	accentColor, titleColor := cardColors(a.Severity, expandos)
 	title := os.Getenv("ALERT_CARD_SUBJECT")
 	summary := os.Getenv("MS_TEAMS_CARD_SUBJECT")
-	errMsg := fmt.Sprintf("%+v", err)
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostname = "hostname_unknown"
@@ -89,12 +176,60 @@ func NewMsTeam(err error, expandos *Expandos) MsTeam {
 		if expandos.MsTeamsCardSubject != "" {
 			summary = expandos.MsTeamsCardSubject
 		}
-		if expandos.MsTeamsError != "" {
-			errMsg = expandos.MsTeamsError
-		}
 	}
 
-	return MsTeam{
+	mentions := cardMentions(a)
+	body := []interface{}{
+		textBlock{
+			Type:   "TextBlock",
+			Text:   cardTitle(title, a.Severity),
+			ID:     "title",
+			Size:   "large",
+			Weight: "bolder",
+			Color:  titleColor,
+		},
+	}
+	if len(mentions) != 0 {
+		body = append(body, mentionBlock(mentions))
+	}
+	facts := []fact{
+		{
+			Title: "Title:",
+			Value: title,
+		},
+		{
+			Title: "Summary:",
+			Value: summary,
+		},
+		{
+			Title: "Hostname:",
+			Value: hostname,
+		},
+	}
+	if env := os.Getenv("APP_ENV"); env != "" {
+		facts = append(facts, fact{Title: "Env:", Value: env})
+	}
+	if occurrences := a.occurrences.Summary(time.Now()); occurrences != "" {
+		facts = append(facts, fact{Title: "Occurrences:", Value: occurrences})
+	}
+	for _, f := range contextFields(a.Context) {
+		facts = append(facts, fact{Title: f.Key + ":", Value: f.Value})
+	}
+	body = append(body,
+		factSet{
+			Type:  "FactSet",
+			Facts: facts,
+			ID:    "acFactSet",
+		},
+		codeBlock{
+			Type:        "CodeBlock",
+			CodeSnippet: errMsg,
+			FontType:    "monospace",
+			Wrap:        true,
+		},
+	)
+
+	card := MsTeam{
 		Type: "message",
 		Attachments: []attachment{
 			{
@@ -104,100 +239,149 @@ func NewMsTeam(err error, expandos *Expandos) MsTeam {
 					Schema:      "http://adaptivecards.io/schemas/adaptive-card.json",
 					Type:        "AdaptiveCard",
 					Version:     "1.4",
-					AccentColor: "bf0000",
-					Body: []interface{}{
-						textBlock{
-							Type:   "TextBlock",
-							Text:   title,
-							ID:     "title",
-							Size:   "large",
-							Weight: "bolder",
-							Color:  "accent",
-						},
-						factSet{
-							Type: "FactSet",
-							Facts: []fact{
-								{
-									Title: "Title:",
-									Value: title,
-								},
-								{
-									Title: "Summary:",
-									Value: summary,
-								},
-								{
-									Title: "Hostname:",
-									Value: hostname,
-								},
-							},
-							ID: "acFactSet",
-						},
-						codeBlock{
-							Type:        "CodeBlock",
-							CodeSnippet: errMsg,
-							FontType:    "monospace",
-							Wrap:        true,
-						},
-					},
+					AccentColor: accentColor,
+					Body:        body,
+					Actions:     cardActions(a, time.Now()),
 					MSTeams: msTeams{
-						Width: "Full",
+						Width:    "Full",
+						Entities: mentionEntities(mentions),
 					},
 				},
 			},
 		},
 	}
+
+	if templateFile := os.Getenv("MS_TEAMS_CARD_TEMPLATE_FILE"); templateFile != "" {
+		content := card.Attachments[0].Content.(cardContent)
+		data := MsTeamsCardData{
+			Title:       title,
+			Summary:     summary,
+			Hostname:    hostname,
+			Error:       errMsg,
+			AccentColor: accentColor,
+			TitleColor:  titleColor,
+			Severity:    string(a.Severity),
+			AppName:     os.Getenv("APP_NAME"),
+			AppEnv:      os.Getenv("APP_ENV"),
+			Fingerprint: a.fingerprint(),
+			Context:     contextFields(a.Context),
+			Time:        time.Now(),
+			Occurrences: a.occurrences,
+			Actions:     content.Actions,
+			Entities:    content.MSTeams.Entities,
+		}
+		rendered, err := renderCardTemplate(templateFile, data)
+		if err != nil {
+			card.err = err
+			return card
+		}
+		card.Attachments[0].Content = rendered
+	}
+	return card
 }
 
-// Send is implementation of interface AlertNotification's Send()
-func (card *MsTeam) Send() (err error) {
-	requestBody, err := json.Marshal(card)
-	if err != nil {
-		return err
+// MsTeamsWebhookType is the flavour of the incoming webhook set in MS_TEAMS_WEBHOOK
+type MsTeamsWebhookType string
+
+// Supported webhook flavours
+const (
+	// MsTeamsConnector is the legacy Office 365 connector webhook
+	MsTeamsConnector MsTeamsWebhookType = "connector"
+	// MsTeamsWorkflows is the Workflows (Power Automate) "When a Teams webhook request is received" trigger
+	MsTeamsWorkflows MsTeamsWebhookType = "workflows"
+)
+
+// workflowsMessage is the envelope expected by Workflows, it rejects the null contentUrl of connectors
+type workflowsMessage struct {
+	Type        string                `json:"type"`
+	Attachments []workflowsAttachment `json:"attachments"`
+}
+
+type workflowsAttachment struct {
+	ContentType string      `json:"contentType"`
+	Content     interface{} `json:"content"`
+}
+
+// microsoftError is the error body returned by Workflows webhooks and Microsoft Graph
+type microsoftError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// describeMicrosoftError formats the error code and message of the body, or the raw body
+func describeMicrosoftError(statusCode int, body []byte) string {
+	var msErr microsoftError
+	if json.Unmarshal(body, &msErr) == nil && msErr.Error.Code != "" {
+		return fmt.Sprintf("%d %s: %s", statusCode, msErr.Error.Code, msErr.Error.Message)
+	}
+	return string(body)
+}
+
+func getMsTeamsWebhookType() MsTeamsWebhookType {
+	if MsTeamsWebhookType(strings.ToLower(os.Getenv("MS_TEAMS_WEBHOOK_TYPE"))) == MsTeamsWorkflows {
+		return MsTeamsWorkflows
 	}
+	return MsTeamsConnector
+}
 
-	var client http.Client
-	timeout := time.Duration(5 * time.Second)
-	proxyURL := os.Getenv("MS_TEAMS_PROXY_URL")
+// payload wraps the card as expected by the webhook flavour
+func (card *MsTeam) payload(webhookType MsTeamsWebhookType) ([]byte, error) {
+	if webhookType != MsTeamsWorkflows {
+		return json.Marshal(card)
+	}
+	message := workflowsMessage{Type: card.Type}
+	for _, a := range card.Attachments {
+		message.Attachments = append(message.Attachments, workflowsAttachment{
+			ContentType: a.ContentType,
+			Content:     a.Content,
+		})
+	}
+	return json.Marshal(message)
+}
 
-	if proxyURL != "" {
-		proxy, err := url.Parse(proxyURL)
-		if err != nil {
-			return err
-		}
-		transport := &http.Transport{Proxy: http.ProxyURL(proxy)}
-		client = http.Client{
-			Transport: transport,
-			Timeout:   timeout,
-		}
-	} else {
-		client = http.Client{
-			Timeout: timeout,
-		}
+// isAccepted tells if the webhook took the card. Connectors answer 202, Workflows 200 or 202
+func isAccepted(webhookType MsTeamsWebhookType, statusCode int) bool {
+	if webhookType == MsTeamsWorkflows {
+		return statusCode == http.StatusOK || statusCode == http.StatusAccepted
 	}
+	return statusCode == http.StatusAccepted
+}
 
-	wb := os.Getenv("MS_TEAMS_WEBHOOK")
-	if len(wb) == 0 {
-		return errors.New("cannot send alert to MSTeams.MS_TEAMS_WEBHOOK is not set in the environment. ")
+// Send is implementation of interface AlertNotification's Send()
+func (card *MsTeam) Send() (err error) {
+	if card.err != nil {
+		return card.err
 	}
-	request, err := http.NewRequest("POST", wb, bytes.NewBuffer(requestBody))
-	request.Header.Set("Content-type", "application/json")
+	webhookType := getMsTeamsWebhookType()
+	requestBody, err := card.payload(webhookType)
 	if err != nil {
 		return err
 	}
 
-	resp, err := client.Do(request)
+	client, err := httpClient("MS_TEAMS")
 	if err != nil {
 		return err
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusAccepted {
-		respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
-		if err != nil {
-			return err
-		}
-		return fmt.Errorf("unexpected response from webhook: %s", string(respBody))
+	wb := os.Getenv("MS_TEAMS_WEBHOOK")
+	if len(wb) == 0 {
+		return errors.New("cannot send alert to MSTeams.MS_TEAMS_WEBHOOK is not set in the environment. ")
 	}
-	return
+	_, err = sendWebhookRequest(client, webhookRequest{
+		URL:  wb,
+		Body: requestBody,
+		Accepted: func(statusCode int) bool {
+			return isAccepted(webhookType, statusCode)
+		},
+		Describe: func(statusCode int, body []byte) string {
+			if webhookType == MsTeamsWorkflows {
+				return describeMicrosoftError(statusCode, body)
+			}
+			return string(body)
+		},
+		Signer: NewWebhookSigner("MS_TEAMS"),
+	}, newRetryPolicy("MS_TEAMS"))
+	return err
 }
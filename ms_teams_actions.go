@@ -0,0 +1,99 @@
+package alertnotification
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Adaptive Card action types
+const (
+	ActionOpenURL = "Action.OpenUrl"
+	ActionSubmit  = "Action.Submit"
+)
+
+// MsTeamsAction is a button of the Teams card
+type MsTeamsAction struct {
+	Type  string      // ActionOpenURL when empty
+	Title string      // replaces the configured action with the same title
+	URL   string      // can contain {fingerprint}, {from}, {to}, {app} and {env}
+	Data  interface{} // payload of ActionSubmit
+}
+
+// configuredActions returns the buttons set in env, in the order runbook, log search, dashboard and acknowledge
+func configuredActions() []MsTeamsAction {
+	actions := []MsTeamsAction{}
+	for _, a := range []struct {
+		title string
+		env   string
+	}{
+		{title: "Runbook", env: "ALERT_RUNBOOK_URL"},
+		{title: "Search logs", env: "ALERT_LOG_SEARCH_URL"},
+		{title: "Dashboard", env: "ALERT_DASHBOARD_URL"},
+		{title: "Acknowledge", env: "ALERT_ACK_URL"},
+	} {
+		if u := os.Getenv(a.env); u != "" {
+			actions = append(actions, MsTeamsAction{Type: ActionOpenURL, Title: a.title, URL: u})
+		}
+	}
+	return actions
+}
+
+// logSearchWindow is the time range put in {from} and {to}, default 15mn
+func logSearchWindow() time.Duration {
+	window := 15
+	if len(os.Getenv("ALERT_LOG_SEARCH_WINDOW_MINUTES")) != 0 {
+		if w, err := strconv.Atoi(os.Getenv("ALERT_LOG_SEARCH_WINDOW_MINUTES")); err == nil {
+			window = w
+		}
+	}
+	return time.Duration(window) * time.Minute
+}
+
+// cardActions merges configured and per alert actions and expands the URL placeholders
+func cardActions(a *Alert, now time.Time) []action {
+	merged := configuredActions()
+	if a.Expandos != nil {
+		for _, ea := range a.Expandos.MsTeamsActions {
+			if i := actionIndex(merged, ea.Title); i >= 0 {
+				merged[i] = ea
+			} else {
+				merged = append(merged, ea)
+			}
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+
+	r := strings.NewReplacer(
+		"{fingerprint}", url.QueryEscape(a.fingerprint()),
+		"{from}", url.QueryEscape(now.Add(-logSearchWindow()).UTC().Format(time.RFC3339)),
+		"{to}", url.QueryEscape(now.UTC().Format(time.RFC3339)),
+		"{app}", url.QueryEscape(os.Getenv("APP_NAME")),
+		"{env}", url.QueryEscape(os.Getenv("APP_ENV")),
+	)
+	actions := make([]action, 0, len(merged))
+	for _, ma := range merged {
+		act := action{Type: ma.Type, Title: ma.Title, URL: r.Replace(ma.URL), Data: ma.Data}
+		if act.Type == "" {
+			act.Type = ActionOpenURL
+		}
+		if act.Type == ActionSubmit {
+			act.URL = ""
+		}
+		actions = append(actions, act)
+	}
+	return actions
+}
+
+func actionIndex(actions []MsTeamsAction, title string) int {
+	for i := range actions {
+		if actions[i].Title == title {
+			return i
+		}
+	}
+	return -1
+}
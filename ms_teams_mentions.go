@@ -0,0 +1,88 @@
+package alertnotification
+
+import (
+	"os"
+	"strings"
+)
+
+// MsTeamsMention is a user or tag notified by the card
+type MsTeamsMention struct {
+	Name string // display name, rendered as <at>Name</at>
+	ID   string // AAD object ID or UPN of a user, or ID of a tag
+}
+
+type mentionEntity struct {
+	Type      string    `json:"type"`
+	Text      string    `json:"text"`
+	Mentioned mentioned `json:"mentioned"`
+}
+
+type mentioned struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// parseMentions reads "Name|id" pairs separated by comma
+func parseMentions(value string) []MsTeamsMention {
+	mentions := []MsTeamsMention{}
+	for _, m := range strings.Split(value, ",") {
+		name, id, found := strings.Cut(m, "|")
+		name, id = strings.TrimSpace(name), strings.TrimSpace(id)
+		if !found || name == "" || id == "" {
+			continue
+		}
+		mentions = append(mentions, MsTeamsMention{Name: name, ID: id})
+	}
+	return mentions
+}
+
+// cardMentions merges MS_TEAMS_MENTIONS, MS_TEAMS_MENTIONS_{SEVERITY} and the alert expandos
+func cardMentions(a *Alert) []MsTeamsMention {
+	all := parseMentions(os.Getenv("MS_TEAMS_MENTIONS"))
+	if a.Severity != "" {
+		all = append(all, parseMentions(os.Getenv("MS_TEAMS_MENTIONS_"+strings.ToUpper(string(a.Severity))))...)
+	}
+	if a.Expandos != nil {
+		all = append(all, a.Expandos.MsTeamsMentions...)
+	}
+
+	mentions := []MsTeamsMention{}
+	seen := map[string]bool{}
+	for _, m := range all {
+		if seen[m.ID] {
+			continue
+		}
+		seen[m.ID] = true
+		mentions = append(mentions, m)
+	}
+	return mentions
+}
+
+// mentionBlock is the card text holding the <at> tags, Teams only notifies mentions present in the text
+func mentionBlock(mentions []MsTeamsMention) textBlock {
+	tags := make([]string, 0, len(mentions))
+	for _, m := range mentions {
+		tags = append(tags, "<at>"+m.Name+"</at>")
+	}
+	return textBlock{
+		Type: "TextBlock",
+		Text: strings.Join(tags, " "),
+		ID:   "mentions",
+		Wrap: true,
+	}
+}
+
+func mentionEntities(mentions []MsTeamsMention) []mentionEntity {
+	if len(mentions) == 0 {
+		return nil
+	}
+	entities := make([]mentionEntity, 0, len(mentions))
+	for _, m := range mentions {
+		entities = append(entities, mentionEntity{
+			Type:      "mention",
+			Text:      "<at>" + m.Name + "</at>",
+			Mentioned: mentioned{ID: m.ID, Name: m.Name},
+		})
+	}
+	return entities
+}
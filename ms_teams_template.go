@@ -0,0 +1,111 @@
+package alertnotification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MsTeamsCardData is the data available in custom Adaptive Card templates.
+// Fields can be used with Go templates, eg. {{json .Error}}, or Adaptive Card expressions, eg. "${Error}" or "${Occurrences.Count}"
+type MsTeamsCardData struct {
+	Title       string
+	Summary     string
+	Hostname    string
+	Error       string
+	AccentColor string
+	TitleColor  string
+	Severity    string
+	AppName     string
+	AppEnv      string
+	Fingerprint string
+	Time        time.Time
+	Context     []ContextField
+	Occurrences OccurrenceStats // throttled since the last alert, eg. {{.Occurrences.Count}}
+	Actions     interface{}     // card actions, use {{json .Actions}}
+	Entities    interface{}     // mention entities, use {{json .Entities}}
+}
+
+// cardExpression matches the Adaptive Card expressions of a template, eg. ${Title} or ${Occurrences.Count}
+var cardExpression = regexp.MustCompile(`\$\{(\w+(?:\.\w+)*)\}`)
+
+var cardTemplateFuncs = template.FuncMap{
+	// json encodes a value, strings are quoted and escaped
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// jsonString escapes a value to be written inside a JSON string
+	"jsonString": func(v interface{}) string {
+		quoted, _ := json.Marshal(fmt.Sprint(v))
+		return string(quoted[1 : len(quoted)-1])
+	},
+}
+
+// renderCardTemplate fills the Adaptive Card template file and validates the result as JSON
+func renderCardTemplate(templateFile string, data MsTeamsCardData) (json.RawMessage, error) {
+	source, err := os.ReadFile(templateFile)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(templateFile)).Funcs(cardTemplateFuncs).Parse(string(expandCardExpressions(source)))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	rendered := out.Bytes()
+
+	var card struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(rendered, &card); err != nil {
+		return nil, fmt.Errorf("card template %s is not valid JSON: %w", templateFile, err)
+	}
+	if card.Type != "AdaptiveCard" {
+		return nil, errors.New("card template " + templateFile + " must render an object of type AdaptiveCard")
+	}
+	return json.RawMessage(rendered), nil
+}
+
+// expandCardExpressions turns the ${Field} of the template source into Go template actions, before the data is filled in.
+// Unknown fields are kept
+func expandCardExpressions(source []byte) []byte {
+	return cardExpression.ReplaceAllFunc(source, func(expr []byte) []byte {
+		path := string(cardExpression.FindSubmatch(expr)[1])
+		if !isCardField(path) {
+			return expr
+		}
+		return []byte("{{jsonString ." + path + "}}")
+	})
+}
+
+// isCardField tells if path, eg. "Occurrences.Count", is a field or a method of MsTeamsCardData
+func isCardField(path string) bool {
+	t := reflect.TypeOf(MsTeamsCardData{})
+	for _, name := range strings.Split(path, ".") {
+		if m, ok := t.MethodByName(name); ok && m.Type.NumIn() == 1 && m.Type.NumOut() > 0 {
+			t = m.Type.Out(0)
+			continue
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+		f, ok := t.FieldByName(name)
+		if !ok || !f.IsExported() {
+			return false
+		}
+		t = f.Type
+	}
+	return true
+}
@@ -0,0 +1,295 @@
+package alertnotification
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_cardColors(t *testing.T) {
+	tests := []struct {
+		name       string
+		severity   Severity
+		expandos   *Expandos
+		env        map[string]string
+		wantAccent string
+		wantTitle  string
+	}{
+		{name: "default", wantAccent: "bf0000", wantTitle: "accent"},
+		{name: "theme_color", env: map[string]string{"ALERT_THEME_COLOR": "#ff5864"}, wantAccent: "ff5864", wantTitle: "accent"},
+		{name: "warning", severity: SeverityWarning, env: map[string]string{"ALERT_THEME_COLOR": "ff5864"}, wantAccent: "ffb900", wantTitle: "warning"},
+		{name: "critical", severity: SeverityCritical, wantAccent: "bf0000", wantTitle: "attention"},
+		{name: "resolved", severity: SeverityResolved, wantAccent: "2eb886", wantTitle: "good"},
+		{name: "severity_env", severity: SeverityWarning, env: map[string]string{"ALERT_THEME_COLOR_WARNING": "ff8c00"}, wantAccent: "ff8c00", wantTitle: "warning"},
+		{name: "expandos", severity: SeverityWarning, expandos: &Expandos{MsTeamsThemeColor: "123456"}, wantAccent: "123456", wantTitle: "warning"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("ALERT_THEME_COLOR")
+			os.Unsetenv("ALERT_THEME_COLOR_WARNING")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			accent, title := cardColors(tt.severity, tt.expandos)
+			if accent != tt.wantAccent || title != tt.wantTitle {
+				t.Errorf("cardColors() = %v, %v, want %v, %v", accent, title, tt.wantAccent, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func Test_newAlertMsTeam(t *testing.T) {
+	setEnv()
+	a := &Alert{Error: errors.New("card error"), Severity: SeverityResolved}
+	card := newAlertMsTeam(a)
+	content := card.Attachments[0].Content.(cardContent)
+	if content.AccentColor != "2eb886" {
+		t.Errorf("newAlertMsTeam() AccentColor = %v, want 2eb886", content.AccentColor)
+	}
+	if title := content.Body[0].(textBlock); title.Color != "good" {
+		t.Errorf("newAlertMsTeam() title Color = %v, want good", title.Color)
+	}
+}
+
+// newFakeWebhook emulates a Teams incoming webhook of the given flavour
+func newFakeWebhook(t *testing.T, webhookType MsTeamsWebhookType, fail bool, payloads *[]map[string]interface{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("webhook received invalid json: %v", err)
+		}
+		*payloads = append(*payloads, payload)
+		switch {
+		case webhookType == MsTeamsWorkflows && fail:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"TriggerInputSchemaMismatch","message":"The input body for trigger does not match"}}`))
+		case webhookType == MsTeamsWorkflows:
+			w.WriteHeader(http.StatusOK)
+		case fail:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Summary or Text is required."))
+		default:
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("1"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMsTeam_Send(t *testing.T) {
+	tests := []struct {
+		name           string
+		webhookType    MsTeamsWebhookType
+		fail           bool
+		wantErr        string
+		wantContentURL bool
+	}{
+		{name: "connector", webhookType: MsTeamsConnector, wantContentURL: true},
+		{name: "connector_error", webhookType: MsTeamsConnector, fail: true, wantErr: "Summary or Text is required.", wantContentURL: true},
+		{name: "workflows", webhookType: MsTeamsWorkflows},
+		{name: "workflows_error", webhookType: MsTeamsWorkflows, fail: true, wantErr: "TriggerInputSchemaMismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv()
+			var payloads []map[string]interface{}
+			server := newFakeWebhook(t, tt.webhookType, tt.fail, &payloads)
+			t.Setenv("MS_TEAMS_WEBHOOK", server.URL)
+			t.Setenv("MS_TEAMS_WEBHOOK_TYPE", string(tt.webhookType))
+
+			card := NewMsTeam(errors.New("webhook error"), nil)
+			err := card.Send()
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("MsTeam.Send() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(payloads) != 1 {
+				t.Fatalf("webhook received %v payloads, want 1", len(payloads))
+			}
+			attachment := payloads[0]["attachments"].([]interface{})[0].(map[string]interface{})
+			if _, ok := attachment["contentUrl"]; ok != tt.wantContentURL {
+				t.Errorf("payload contentUrl present = %v, want %v", ok, tt.wantContentURL)
+			}
+			if attachment["content"].(map[string]interface{})["type"] != "AdaptiveCard" {
+				t.Errorf("payload content = %v, want AdaptiveCard", attachment["content"])
+			}
+		})
+	}
+}
+
+func Test_cardActions(t *testing.T) {
+	setEnv()
+	t.Setenv("ALERT_RUNBOOK_URL", "https://wiki.example.com/runbook")
+	t.Setenv("ALERT_LOG_SEARCH_URL", "https://logs.example.com/search?q={fingerprint}&from={from}&to={to}&app={app}")
+	t.Setenv("ALERT_LOG_SEARCH_WINDOW_MINUTES", "10")
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := &Alert{
+		Error: errors.New("action error"),
+		Expandos: &Expandos{
+			MsTeamsActions: []MsTeamsAction{
+				{Title: "Runbook", URL: "https://wiki.example.com/other"},
+				{Type: ActionSubmit, Title: "Ack", URL: "ignored", Data: map[string]string{"ack": "{fingerprint}"}},
+			},
+		},
+	}
+	want := []action{
+		{Type: ActionOpenURL, Title: "Runbook", URL: "https://wiki.example.com/other"},
+		{
+			Type:  ActionOpenURL,
+			Title: "Search logs",
+			URL:   "https://logs.example.com/search?q=" + a.fingerprint() + "&from=2024-01-02T02%3A54%3A05Z&to=2024-01-02T03%3A04%3A05Z&app=golang",
+		},
+		{Type: ActionSubmit, Title: "Ack", Data: map[string]string{"ack": "{fingerprint}"}},
+	}
+	if got := cardActions(a, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("cardActions() = %+v, want %+v", got, want)
+	}
+	if got := cardActions(&Alert{Error: errors.New("action error")}, now); len(got) != 2 {
+		t.Errorf("cardActions() without expandos = %+v, want 2 configured actions", got)
+	}
+}
+
+func Test_cardMentions(t *testing.T) {
+	setEnv()
+	t.Setenv("MS_TEAMS_MENTIONS", "Team Lead|lead@example.com, broken")
+	t.Setenv("MS_TEAMS_MENTIONS_CRITICAL", "On Call|5f3b1c2a-0000-0000-0000-000000000000,Team Lead|lead@example.com")
+	a := &Alert{
+		Error:    errors.New("mention error"),
+		Severity: SeverityCritical,
+		Expandos: &Expandos{MsTeamsMentions: []MsTeamsMention{{Name: "SRE", ID: "tag-id"}}},
+	}
+	want := []MsTeamsMention{
+		{Name: "Team Lead", ID: "lead@example.com"},
+		{Name: "On Call", ID: "5f3b1c2a-0000-0000-0000-000000000000"},
+		{Name: "SRE", ID: "tag-id"},
+	}
+	if got := cardMentions(a); !reflect.DeepEqual(got, want) {
+		t.Errorf("cardMentions() = %+v, want %+v", got, want)
+	}
+
+	content := newAlertMsTeam(a).Attachments[0].Content.(cardContent)
+	if text := content.Body[1].(textBlock).Text; text != "<at>Team Lead</at> <at>On Call</at> <at>SRE</at>" {
+		t.Errorf("newAlertMsTeam() mention text = %v", text)
+	}
+	if len(content.MSTeams.Entities) != 3 || content.MSTeams.Entities[1].Mentioned.ID != want[1].ID {
+		t.Errorf("newAlertMsTeam() entities = %+v", content.MSTeams.Entities)
+	}
+
+	a.Severity = SeverityWarning
+	a.Expandos = nil
+	if got := cardMentions(a); len(got) != 1 {
+		t.Errorf("cardMentions() for warning = %+v, want only MS_TEAMS_MENTIONS", got)
+	}
+}
+
+func Test_renderCardTemplate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	data := MsTeamsCardData{
+		Title:       "Alert",
+		Error:       "line 1\n\"quoted\"",
+		Summary:     "${Title} ${Hostname}",
+		AccentColor: "bf0000",
+		Actions:     []action{{Type: ActionOpenURL, Title: "Runbook", URL: "https://wiki.example.com"}},
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "go_template",
+			template: `{"type":"AdaptiveCard","body":[{"type":"TextBlock","text":{{json .Error}}}],"actions":{{json .Actions}}}`,
+			want:     `{"type":"AdaptiveCard","body":[{"type":"TextBlock","text":"line 1\n\"quoted\""}],"actions":[{"type":"Action.OpenUrl","title":"Runbook","url":"https://wiki.example.com"}]}`,
+		},
+		{
+			name:     "adaptive_expressions",
+			template: `{"type":"AdaptiveCard","accentColor":"${AccentColor}","body":[{"type":"TextBlock","text":"${Title}: ${Error} ${Unknown} ${Occurrences.Count}"}]}`,
+			want:     `{"type":"AdaptiveCard","accentColor":"bf0000","body":[{"type":"TextBlock","text":"Alert: line 1\n\"quoted\" ${Unknown} 1"}]}`,
+		},
+		{
+			name:     "expressions_in_data",
+			template: `{"type":"AdaptiveCard","body":[{"type":"TextBlock","text":"${Summary}"}]}`,
+			want:     `{"type":"AdaptiveCard","body":[{"type":"TextBlock","text":"${Title} ${Hostname}"}]}`,
+		},
+		{
+			name:     "invalid_json",
+			template: `{"type":"AdaptiveCard","body":[{{.Error}}]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "not_adaptive_card",
+			template: `{"type":"message"}`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderCardTemplate(write(tt.name+".json", tt.template), data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderCardTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("renderCardTemplate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("send_fails_on_invalid_template", func(t *testing.T) {
+		setEnv()
+		t.Setenv("MS_TEAMS_CARD_TEMPLATE_FILE", write("broken.json", `{"type":`))
+		card := NewMsTeam(errors.New("template error"), nil)
+		if err := card.Send(); err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+			t.Errorf("MsTeam.Send() error = %v, want template validation error", err)
+		}
+	})
+}
+
+func Test_newAlertMsTeam_context(t *testing.T) {
+	setEnv()
+	a := NewAlert(errors.New("context error"), nil)
+	a.WithContext("region", "ap-northeast-1").WithContext("request_id", "req-42")
+	content := newAlertMsTeam(&a).Attachments[0].Content.(cardContent)
+	facts := content.Body[1].(factSet).Facts
+	want := []fact{
+		{Title: "Env:", Value: "local"},
+		{Title: "region:", Value: "ap-northeast-1"},
+		{Title: "request_id:", Value: "req-42"},
+	}
+	if got := facts[3:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("newAlertMsTeam() context facts = %+v, want %+v", got, want)
+	}
+}
+
+func Test_newAlertMsTeam_payloadLimit(t *testing.T) {
+	setEnv()
+	t.Setenv("MS_TEAMS_MAX_PAYLOAD_BYTES", "4000")
+	lines := []string{"first line of the error"}
+	for i := 0; i < 500; i++ {
+		lines = append(lines, "\t\"frame\" <"+strings.Repeat("x", 20)+">")
+	}
+	lines = append(lines, "last frame")
+	card := newAlertMsTeam(&Alert{Error: errors.New(strings.Join(lines, "\n"))})
+	size, err := card.size()
+	if err != nil || size > 4000 {
+		t.Fatalf("MsTeam.size() = %v, %v, want <= 4000", size, err)
+	}
+	snippet := card.Attachments[0].Content.(cardContent).Body[2].(codeBlock).CodeSnippet
+	if !strings.HasPrefix(snippet, "first line") || !strings.HasSuffix(snippet, "last frame") || !strings.Contains(snippet, "lines elided") {
+		t.Errorf("newAlertMsTeam() snippet = %q, want head and tail kept", snippet)
+	}
+}
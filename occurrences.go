@@ -0,0 +1,97 @@
+package alertnotification
+
+import (
+	"fmt"
+	"time"
+)
+
+// occurrencesTTL keeps the suppressed occurrences of an error until its next alert, or a day without occurrence
+const occurrencesTTL = 24 * time.Hour
+
+// OccurrenceStats are the occurrences of an error dropped by throttling since its last alert
+type OccurrenceStats struct {
+	Suppressed int       `json:"suppressed"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Count is the number of occurrences including the alerted one
+func (s OccurrenceStats) Count() int {
+	return s.Suppressed + 1
+}
+
+// Summary describes the occurrences, eg. "this error occurred 413 times in the last 5m0s", empty without suppressed occurrence
+func (s OccurrenceStats) Summary(now time.Time) string {
+	if s.Suppressed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("this error occurred %d times in the last %v", s.Count(), now.Sub(s.FirstSeen).Round(time.Second))
+}
+
+// recordSuppressed counts an occurrence of the error of key dropped by throttling,
+// in {key}_suppressed_count, {key}_first_seen and {key}_last_seen
+func recordSuppressed(store ThrottleStore, key string, now time.Time) error {
+	if _, err := store.Increment(key+"_suppressed_count", 1, occurrencesTTL); err != nil {
+		return err
+	}
+	seen := []byte(now.Format(time.RFC3339Nano))
+	// only the first occurrence since the last alert sets its time
+	if _, err := store.CompareAndSwap(key+"_first_seen", nil, seen, occurrencesTTL); err != nil {
+		return err
+	}
+	return store.Set(key+"_last_seen", seen, occurrencesTTL)
+}
+
+// readSuppressed returns the suppressed occurrences of the error of key, they are kept until clearSuppressed
+func readSuppressed(store ThrottleStore, key string) (OccurrenceStats, error) {
+	count, _, err := store.Get(key + "_suppressed_count")
+	if err != nil || parseCounter(count) <= 0 {
+		return OccurrenceStats{}, err
+	}
+	stats := OccurrenceStats{Suppressed: int(parseCounter(count))}
+	if stats.FirstSeen, err = readTime(store, key+"_first_seen"); err != nil {
+		return OccurrenceStats{}, err
+	}
+	if stats.LastSeen, err = readTime(store, key+"_last_seen"); err != nil {
+		return OccurrenceStats{}, err
+	}
+	// concurrent occurrences write their times in any order
+	if stats.LastSeen.Before(stats.FirstSeen) {
+		stats.LastSeen = stats.FirstSeen
+	}
+	if stats.FirstSeen.IsZero() {
+		stats.FirstSeen = stats.LastSeen
+	}
+	return stats, nil
+}
+
+// clearSuppressed removes the occurrences reported by an alert once it was sent, the ones suppressed meanwhile are kept
+func clearSuppressed(store ThrottleStore, key string, reported OccurrenceStats) error {
+	if reported.Suppressed == 0 {
+		return nil
+	}
+	remaining, err := store.Increment(key+"_suppressed_count", -int64(reported.Suppressed), occurrencesTTL)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		// suppressed while the alert was sent, after the last reported occurrence
+		return store.Set(key+"_first_seen", []byte(reported.LastSeen.Format(time.RFC3339Nano)), occurrencesTTL)
+	}
+	if remaining < 0 {
+		// the store was cleaned meanwhile
+		if err := store.Delete(key + "_suppressed_count"); err != nil {
+			return err
+		}
+	}
+	return store.Delete(key + "_first_seen")
+}
+
+func readTime(store ThrottleStore, key string) (time.Time, error) {
+	value, found, err := store.Get(key)
+	if err != nil || !found {
+		return time.Time{}, err
+	}
+	t, _ := time.Parse(time.RFC3339Nano, string(value))
+	return t, nil
+}
@@ -0,0 +1,171 @@
+package alertnotification
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOccurrenceStats_Summary(t *testing.T) {
+	now := time.Now()
+	if got := (OccurrenceStats{}).Summary(now); got != "" {
+		t.Errorf("OccurrenceStats.Summary() = %q without suppressed occurrence, want empty", got)
+	}
+	s := OccurrenceStats{Suppressed: 411, FirstSeen: now.Add(-5 * time.Minute), LastSeen: now}
+	if got, want := s.Summary(now), "this error occurred 412 times in the last 5m0s"; got != want {
+		t.Errorf("OccurrenceStats.Summary() = %q, want %q", got, want)
+	}
+}
+
+func Test_recordSuppressed(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := recordSuppressed(store, "key", start.Add(time.Duration(i)*time.Second)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats, err := readSuppressed(store, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Suppressed != 20 {
+		t.Errorf("readSuppressed() Suppressed = %v, want 20", stats.Suppressed)
+	}
+	if stats.FirstSeen.IsZero() || stats.LastSeen.Before(stats.FirstSeen) {
+		t.Errorf("readSuppressed() FirstSeen = %v, LastSeen = %v", stats.FirstSeen, stats.LastSeen)
+	}
+
+	// suppressed while the alert is sent
+	recordSuppressed(store, "key", start.Add(time.Minute))
+	if err := clearSuppressed(store, "key", stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := readSuppressed(store, "key"); stats.Suppressed != 1 {
+		t.Errorf("clearSuppressed() left %v occurrences, want the one suppressed meanwhile", stats.Suppressed)
+	}
+	clearSuppressed(store, "key", OccurrenceStats{Suppressed: 1})
+	if stats, _ := readSuppressed(store, "key"); stats.Suppressed != 0 || !stats.FirstSeen.IsZero() {
+		t.Errorf("clearSuppressed() left %+v", stats)
+	}
+}
+
+func TestAlert_Notify_occurrencesAfterFailure(t *testing.T) {
+	var status atomic.Int32
+	var mu sync.Mutex
+	var cards []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		cards = append(cards, string(body))
+		mu.Unlock()
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+	resetHTTPClient(t)
+	t.Setenv("EMAIL_ALERT_ENABLED", "")
+	t.Setenv("MS_GRAPH_ALERT_ENABLED", "")
+	t.Setenv("MS_TEAMS_ALERT_ENABLED", "true")
+	t.Setenv("MS_TEAMS_WEBHOOK", server.URL)
+	t.Setenv("MS_TEAMS_MAX_RETRIES", "0")
+	t.Setenv("THROTTLE_ENABLED", "")
+	t.Setenv("THROTTLE_GRACE_SECONDS", "0")
+	store := NewMemoryStore(0, 0)
+	SetThrottleStore(store)
+	defer SetThrottleStore(nil)
+
+	notify := func(wantErr bool) {
+		t.Helper()
+		a := NewAlert(errors.New("db timeout"), nil)
+		if err := a.Notify(); (err != nil) != wantErr {
+			t.Fatalf("Alert.Notify() error = %v, wantErr %v", err, wantErr)
+		}
+	}
+	status.Store(http.StatusAccepted)
+	notify(false)
+	for i := 0; i < 3; i++ {
+		notify(false)
+	}
+
+	// the throttle duration is over but the alert fails
+	key := Fingerprint(errors.New("db timeout"))
+	store.Delete(key)
+	status.Store(http.StatusInternalServerError)
+	notify(true)
+
+	store.Delete(key)
+	status.Store(http.StatusAccepted)
+	notify(false)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(cards) != 3 || !strings.Contains(cards[2], "this error occurred 4 times") {
+		t.Errorf("Alert.Notify() sent %v cards, want the last one to report the 3 occurrences suppressed before the failure", len(cards))
+	}
+	if stats, _ := readSuppressed(store, key); stats.Suppressed != 0 {
+		t.Errorf("Alert.Notify() did not clear the reported occurrences, %+v left", stats)
+	}
+}
+
+func TestThrottler_check_occurrences(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	th := &Throttler{ThrottleDuration: 5 * time.Minute, Store: store}
+
+	if throttled, _ := th.check("key", th.ThrottleDuration); throttled {
+		t.Fatalf("Throttler.check() throttled the first occurrence")
+	}
+	for i := 0; i < 3; i++ {
+		if throttled, _ := th.check("key", th.ThrottleDuration); !throttled {
+			t.Fatalf("Throttler.check() did not throttle occurrence %v", i+2)
+		}
+	}
+
+	// the throttle duration is over
+	store.Delete("key")
+	throttled, stats := th.check("key", th.ThrottleDuration)
+	if throttled {
+		t.Fatalf("Throttler.check() throttled after the throttle duration")
+	}
+	if stats.Suppressed != 3 || stats.Count() != 4 {
+		t.Errorf("Throttler.check() occurrences = %+v, want 3 suppressed", stats)
+	}
+}
+
+func Test_newAlertMsTeam_occurrences(t *testing.T) {
+	a := NewAlert(errors.New("db timeout"), nil)
+	a.occurrences = OccurrenceStats{Suppressed: 411, FirstSeen: time.Now().Add(-5 * time.Minute)}
+	facts := newAlertMsTeam(&a).Attachments[0].Content.(cardContent).Body[1].(factSet).Facts
+	last := facts[len(facts)-1]
+	if last.Title != "Occurrences:" || !strings.HasPrefix(last.Value, "this error occurred 412 times in the last 5m") {
+		t.Errorf("newAlertMsTeam() occurrences fact = %+v", last)
+	}
+}
+
+func TestEmailConfig_renderBody_occurrences(t *testing.T) {
+	data := newEmailTemplateData("Alert", "db timeout")
+	data.OccurrenceCount = 412
+	data.SuppressedCount = 411
+	data.FirstSeen = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	body, err := (&EmailConfig{}).renderBody(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{">412<", ">411<", "First seen", "2024-05-01 10:00:00 UTC"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("EmailConfig.renderBody() = %v, want it to contain %q", body, want)
+		}
+	}
+}
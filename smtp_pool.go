@@ -0,0 +1,203 @@
+package alertnotification
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	defaultPool     *SMTPPool
+	defaultPoolOnce sync.Once
+)
+
+// SMTPPool keeps SMTP connections open between alerts so bursts do not dial and authenticate for every mail
+type SMTPPool struct {
+	MaxIdleConns int           // idle connections kept per server, 0 disables pooling
+	MaxIdleTime  time.Duration // idle connections older than this are closed instead of reused
+
+	mu   sync.Mutex
+	idle map[string][]*pooledSMTPClient
+}
+
+type pooledSMTPClient struct {
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// NewSMTPPool creates SMTPPool
+func NewSMTPPool(maxIdleConns int, maxIdleTime time.Duration) *SMTPPool {
+	return &SMTPPool{
+		MaxIdleConns: maxIdleConns,
+		MaxIdleTime:  maxIdleTime,
+		idle:         map[string][]*pooledSMTPClient{},
+	}
+}
+
+// defaultSMTPPool returns the pool shared by all EmailConfig without their own Pool
+func defaultSMTPPool() *SMTPPool {
+	defaultPoolOnce.Do(func() {
+		maxIdleConns := 2 // default 2 connections
+		maxIdleTime := 60 // default 60sc
+		if len(os.Getenv("SMTP_POOL_MAX_IDLE_CONNS")) != 0 {
+			if n, err := strconv.Atoi(os.Getenv("SMTP_POOL_MAX_IDLE_CONNS")); err == nil {
+				maxIdleConns = n
+			}
+		}
+		if len(os.Getenv("SMTP_POOL_MAX_IDLE_SECONDS")) != 0 {
+			if n, err := strconv.Atoi(os.Getenv("SMTP_POOL_MAX_IDLE_SECONDS")); err == nil {
+				maxIdleTime = n
+			}
+		}
+		defaultPool = NewSMTPPool(maxIdleConns, time.Duration(maxIdleTime)*time.Second)
+	})
+	return defaultPool
+}
+
+// SendMail sends the message with a pooled connection. A broken idle connection is replaced by a new one.
+// PLAIN authentication is used when username is not empty
+func (p *SMTPPool) SendMail(host string, port string, username string, password string, from string, to []string, message []byte) error {
+	addr := net.JoinHostPort(host, port)
+	// connections are authenticated, do not share them between credentials
+	key := addr + "|" + username
+	var auth smtp.Auth
+	if len(strings.TrimSpace(username)) != 0 {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	for {
+		c, reused := p.get(key)
+		var err error
+		if c == nil {
+			if c, err = dialSMTP(addr, host, auth); err != nil {
+				return err
+			}
+		}
+		err = sendWithClient(c.client, from, to, message)
+		if err == nil {
+			p.put(key, c)
+			return nil
+		}
+		c.client.Close()
+		var reply *textproto.Error
+		if !reused || errors.As(err, &reply) {
+			// fresh connection failed or server rejected the mail, reconnecting will not help
+			return err
+		}
+	}
+}
+
+// Close closes all idle connections
+func (p *SMTPPool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = map[string][]*pooledSMTPClient{}
+	p.mu.Unlock()
+
+	var err error
+	for _, clients := range idle {
+		for _, c := range clients {
+			if e := c.client.Quit(); e != nil {
+				c.client.Close()
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// get returns a live idle connection or nil when a new one has to be dialed
+func (p *SMTPPool) get(key string) (*pooledSMTPClient, bool) {
+	for {
+		p.mu.Lock()
+		clients := p.idle[key]
+		if len(clients) == 0 {
+			p.mu.Unlock()
+			return nil, false
+		}
+		c := clients[len(clients)-1]
+		p.idle[key] = clients[:len(clients)-1]
+		p.mu.Unlock()
+
+		if time.Since(c.lastUsed) > p.MaxIdleTime {
+			c.client.Close()
+			continue
+		}
+		// keepalive check, the server may have dropped the connection while idle
+		if err := c.client.Noop(); err != nil {
+			c.client.Close()
+			continue
+		}
+		return c, true
+	}
+}
+
+// put resets the session and keeps the connection for the next mail
+func (p *SMTPPool) put(key string, c *pooledSMTPClient) {
+	if err := c.client.Reset(); err != nil {
+		c.client.Close()
+		return
+	}
+	c.lastUsed = time.Now()
+
+	p.mu.Lock()
+	full := len(p.idle[key]) >= p.MaxIdleConns
+	if !full {
+		p.idle[key] = append(p.idle[key], c)
+	}
+	p.mu.Unlock()
+	if full {
+		c.client.Quit()
+	}
+}
+
+func dialSMTP(addr string, host string, auth smtp.Auth) (*pooledSMTPClient, error) {
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		// same handshake as smtp.SendMail
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				client.Close()
+				return nil, err
+			}
+		}
+		// never fall back to an unauthenticated session, AUTH may have been stripped in transit
+		if ok, _ := client.Extension("AUTH"); !ok {
+			client.Close()
+			return nil, errors.New("smtp: server doesn't support AUTH")
+		}
+		if err = client.Auth(auth); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return &pooledSMTPClient{client: client, lastUsed: time.Now()}, nil
+}
+
+func sendWithClient(client *smtp.Client, from string, to []string, message []byte) error {
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, receiver := range to {
+		if err := client.Rcpt(receiver); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(message); err != nil {
+		return err
+	}
+	return w.Close()
+}
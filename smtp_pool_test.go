@@ -0,0 +1,161 @@
+package alertnotification
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer is a minimal SMTP server recording connections and delivered messages
+type fakeSMTPServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	dials    int
+	messages []string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{listener: l}
+	go s.serve()
+	t.Cleanup(func() {
+		l.Close()
+		s.dropConnections()
+	})
+	return s
+}
+
+func (s *fakeSMTPServer) hostPort() (string, string) {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host, port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.dials++
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-fake")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			// MAIL, RCPT, NOOP, RSET and HELO
+			reply("250 ok")
+		}
+	}
+}
+
+// dropConnections closes all client connections like a server side idle timeout
+func (s *fakeSMTPServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeSMTPServer) stats() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials, len(s.messages)
+}
+
+func TestSMTPPool_SendMail(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxIdleConns int
+		maxIdleTime  time.Duration
+		between      func(s *fakeSMTPServer)
+		wantDials    int
+	}{
+		{name: "reuse", maxIdleConns: 1, maxIdleTime: time.Minute, wantDials: 1},
+		{name: "pool_disabled", maxIdleConns: 0, maxIdleTime: time.Minute, wantDials: 3},
+		{name: "reconnect_dropped", maxIdleConns: 1, maxIdleTime: time.Minute, between: (*fakeSMTPServer).dropConnections, wantDials: 3},
+		{name: "idle_expired", maxIdleConns: 1, maxIdleTime: time.Nanosecond, wantDials: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeSMTPServer(t)
+			host, port := s.hostPort()
+			p := NewSMTPPool(tt.maxIdleConns, tt.maxIdleTime)
+			defer p.Close()
+			for i := 0; i < 3; i++ {
+				if tt.between != nil && i > 0 {
+					tt.between(s)
+				}
+				err := p.SendMail(host, port, "", "", "test@example.com", []string{"receiver.test@example.com"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+				if err != nil {
+					t.Fatalf("SMTPPool.SendMail() error = %v", err)
+				}
+			}
+			dials, messages := s.stats()
+			if dials != tt.wantDials || messages != 3 {
+				t.Errorf("SMTPPool.SendMail() dials = %v, messages = %v, want %v dials and 3 messages", dials, messages, tt.wantDials)
+			}
+		})
+	}
+}
+
+func TestSMTPPool_SendMail_authNotSupported(t *testing.T) {
+	s := newFakeSMTPServer(t)
+	host, port := s.hostPort()
+	p := NewSMTPPool(1, time.Minute)
+	defer p.Close()
+	err := p.SendMail(host, port, "user", "secret", "test@example.com", []string{"receiver.test@example.com"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "doesn't support AUTH") {
+		t.Errorf("SMTPPool.SendMail() with credentials error = %v, want AUTH not supported", err)
+	}
+	if _, messages := s.stats(); messages != 0 {
+		t.Errorf("SMTPPool.SendMail() sent %v messages without authentication", messages)
+	}
+}
@@ -0,0 +1,85 @@
+package alertnotification
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+)
+
+// SuppressionRule tells if an error must not be alerted
+type SuppressionRule interface {
+	Suppress(err error) bool
+}
+
+// SuppressionFunc is a SuppressionRule from a predicate
+type SuppressionFunc func(err error) bool
+
+// Suppress is implementation of SuppressionRule
+func (f SuppressionFunc) Suppress(err error) bool {
+	return f(err)
+}
+
+var (
+	suppressionMu    sync.RWMutex
+	suppressionRules []SuppressionRule
+)
+
+// SetSuppressionRules replaces the rules applied to all alerts, in addition to the rules of each Alert
+func SetSuppressionRules(rules ...SuppressionRule) {
+	suppressionMu.Lock()
+	defer suppressionMu.Unlock()
+	suppressionRules = rules
+}
+
+// SuppressIs suppresses errors matching target with errors.Is, eg. wrapped sentinel errors
+func SuppressIs(target error) SuppressionRule {
+	return SuppressionFunc(func(err error) bool {
+		return errors.Is(err, target)
+	})
+}
+
+// SuppressAs suppresses errors with an error of type T in their chain, as errors.As finds it
+func SuppressAs[T error]() SuppressionRule {
+	return SuppressionFunc(func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	})
+}
+
+// SuppressMatching suppresses errors whose message matches re
+func SuppressMatching(re *regexp.Regexp) SuppressionRule {
+	return SuppressionFunc(func(err error) bool {
+		return re.MatchString(err.Error())
+	})
+}
+
+// SuppressMessage suppresses errors whose message is exactly message
+func SuppressMessage(message string) SuppressionRule {
+	return SuppressionFunc(func(err error) bool {
+		return err.Error() == message
+	})
+}
+
+// SuppressFingerprint suppresses errors with the fingerprint of e, eg. "order 43 failed" for "order 1 failed"
+func SuppressFingerprint(e error) SuppressionRule {
+	fingerprint := Fingerprint(e)
+	return SuppressionFunc(func(err error) bool {
+		return Fingerprint(err) == fingerprint
+	})
+}
+
+// isSuppressed applies the global rules, then rules
+func isSuppressed(err error, rules []SuppressionRule) bool {
+	if err == nil {
+		return false
+	}
+	suppressionMu.RLock()
+	global := suppressionRules
+	suppressionMu.RUnlock()
+	for _, r := range append(append([]SuppressionRule{}, global...), rules...) {
+		if r.Suppress(err) {
+			return true
+		}
+	}
+	return false
+}
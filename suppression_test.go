@@ -0,0 +1,65 @@
+package alertnotification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestAlert_isDoNotAlert_rules(t *testing.T) {
+	errNotFound := errors.New("not found")
+	tests := []struct {
+		name  string
+		err   error
+		rules []SuppressionRule
+		want  bool
+	}{
+		{name: "is_wrapped_sentinel", err: fmt.Errorf("load user 42: %w", errNotFound), rules: []SuppressionRule{SuppressIs(errNotFound)}, want: true},
+		{name: "is_other", err: errors.New("not found"), rules: []SuppressionRule{SuppressIs(errNotFound)}, want: false},
+		{name: "is_context", err: fmt.Errorf("query: %w", context.Canceled), rules: []SuppressionRule{SuppressIs(context.Canceled)}, want: true},
+		{
+			name:  "as_type",
+			err:   fmt.Errorf("read config: %w", &fs.PathError{Op: "open", Path: "/etc/app", Err: os.ErrNotExist}),
+			rules: []SuppressionRule{SuppressAs[*fs.PathError]()},
+			want:  true,
+		},
+		{name: "as_other_type", err: errors.New("open /etc/app"), rules: []SuppressionRule{SuppressAs[*fs.PathError]()}, want: false},
+		{name: "regexp", err: errors.New("GET /health: 503"), rules: []SuppressionRule{SuppressMatching(regexp.MustCompile(`^GET /health\b`))}, want: true},
+		{name: "message", err: errors.New("broken pipe"), rules: []SuppressionRule{SuppressMessage("broken pipe")}, want: true},
+		{name: "message_wrapped", err: fmt.Errorf("write: %w", errors.New("broken pipe")), rules: []SuppressionRule{SuppressMessage("broken pipe")}, want: false},
+		{name: "fingerprint", err: errors.New("timeout after 1533ms"), rules: []SuppressionRule{SuppressFingerprint(errors.New("timeout after 12ms"))}, want: true},
+		{
+			name:  "predicate",
+			err:   errors.New("retrying"),
+			rules: []SuppressionRule{SuppressionFunc(func(err error) bool { return len(err.Error()) < 10 })},
+			want:  true,
+		},
+		{name: "no_rule", err: errors.New("broken pipe"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Alert{Error: tt.err, SuppressionRules: tt.rules}
+			if got := a.isDoNotAlert(); got != tt.want {
+				t.Errorf("Alert.isDoNotAlert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetSuppressionRules(t *testing.T) {
+	SetSuppressionRules(SuppressIs(context.DeadlineExceeded))
+	defer SetSuppressionRules()
+
+	a := &Alert{Error: fmt.Errorf("call api: %w", context.DeadlineExceeded)}
+	if !a.isDoNotAlert() {
+		t.Errorf("Alert.isDoNotAlert() = false, want the global rule to suppress the error")
+	}
+	b := &Alert{Error: errors.New("call api: 500")}
+	if b.isDoNotAlert() {
+		t.Errorf("Alert.isDoNotAlert() = true for an error without rule")
+	}
+}
@@ -0,0 +1,228 @@
+package alertnotification
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GitbookIO/diskache"
+)
+
+// ThrottleStore keeps the throttling state of the errors
+type ThrottleStore interface {
+	// Get returns the value of key, found is false when the key is missing or expired
+	Get(key string) (value []byte, found bool, err error)
+	// Set stores value until ttl is over, for ever when ttl is 0
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes key, missing keys are not an error
+	Delete(key string) error
+	// Clean removes all keys
+	Clean() error
+	// CompareAndSwap stores value only when the current value of key is old, and tells if it did.
+	// A nil old matches a missing or expired key
+	CompareAndSwap(key string, old []byte, value []byte, ttl time.Duration) (swapped bool, err error)
+	// Increment adds delta to the integer value of key and returns the result, a missing or expired key counts from 0.
+	// The ttl restarts with each increment, 0 keeps the key for ever
+	Increment(key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+var (
+	throttleStoreMu      sync.Mutex
+	defaultThrottleStore ThrottleStore
+	diskStores           = map[string]*DiskStore{}
+)
+
+// SetThrottleStore makes all throttlers without Store use store, nil restores the store chosen by THROTTLE_STORE
+func SetThrottleStore(store ThrottleStore) {
+	throttleStoreMu.Lock()
+	defer throttleStoreMu.Unlock()
+	defaultThrottleStore = store
+}
+
+// store returns the store of the throttler: Store, the one of SetThrottleStore, or the disk store of CacheOpt
+func (t *Throttler) store() (ThrottleStore, error) {
+	if t.Store != nil {
+		return t.Store, nil
+	}
+	throttleStoreMu.Lock()
+	defer throttleStoreMu.Unlock()
+	if defaultThrottleStore != nil {
+		return defaultThrottleStore, nil
+	}
+	switch os.Getenv("THROTTLE_STORE") {
+	case "", "disk":
+	case "memory":
+		return memoryStoreFromEnv(), nil
+	case "redis":
+		return redisStoreFromEnv()
+	case "sql":
+		return sqlStoreFromEnv()
+	default:
+		return nil, errors.New("unknown THROTTLE_STORE " + os.Getenv("THROTTLE_STORE"))
+	}
+	if ds, ok := diskStores[t.CacheOpt]; ok {
+		return ds, nil
+	}
+	ds, err := NewDiskStore(t.CacheOpt)
+	if err != nil {
+		return nil, err
+	}
+	diskStores[t.CacheOpt] = ds
+	return ds, nil
+}
+
+// DiskStore is the ThrottleStore on files of a directory, one file per key.
+// CompareAndSwap and Increment hold a lock file inside the directory, so processes sharing the directory are serialized
+type DiskStore struct {
+	dc        *diskache.Diskache
+	directory string
+	mu        sync.Mutex
+}
+
+// diskLockFile is the lock file of CompareAndSwap and Increment, diskache names the files of the keys after their sha256 so it cannot be a key
+const diskLockFile = ".lock"
+
+// expiresPrefix starts the values stored with a TTL, values written without it never expire
+const expiresPrefix = "expires="
+
+// NewDiskStore opens or creates the store in directory
+func NewDiskStore(directory string) (*DiskStore, error) {
+	dc, err := diskache.New(&diskache.Opts{Directory: directory})
+	if err != nil {
+		return nil, err
+	}
+	return &DiskStore{dc: dc, directory: directory}, nil
+}
+
+// Get is implementation of ThrottleStore
+func (s *DiskStore) Get(key string) ([]byte, bool, error) {
+	data, found := s.dc.Get(key)
+	if !found || !strings.HasPrefix(string(data), expiresPrefix) {
+		return data, found, nil
+	}
+	i := strings.IndexByte(string(data), '\n')
+	if i < 0 {
+		return nil, false, nil
+	}
+	expires, err := strconv.ParseInt(string(data[len(expiresPrefix):i]), 10, 64)
+	if err != nil || time.Now().UnixNano() >= expires {
+		return nil, false, nil
+	}
+	return data[i+1:], true, nil
+}
+
+// Set is implementation of ThrottleStore
+func (s *DiskStore) Set(key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return s.write(key, value)
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).UnixNano(), 10)
+	return s.write(key, append([]byte(expiresPrefix+expires+"\n"), value...))
+}
+
+// write stores data as the file of key, and creates the directory again when it was removed after NewDiskStore
+func (s *DiskStore) write(key string, data []byte) error {
+	err := s.dc.Set(key, data)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(s.directory, os.ModePerm); err != nil {
+			return err
+		}
+		err = s.dc.Set(key, data)
+	}
+	return err
+}
+
+// Delete is implementation of ThrottleStore
+func (s *DiskStore) Delete(key string) error {
+	// diskache names the file of a key after its sha256
+	sum := sha256.Sum256([]byte(key))
+	err := os.Remove(filepath.Join(s.directory, hex.EncodeToString(sum[:])))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Clean is implementation of ThrottleStore, it removes the lock file with the directory and the next CompareAndSwap creates it again
+func (s *DiskStore) Clean() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dc.Clean()
+}
+
+// CompareAndSwap is implementation of ThrottleStore
+func (s *DiskStore) CompareAndSwap(key string, old []byte, value []byte, ttl time.Duration) (bool, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	current, found, err := s.Get(key)
+	if err != nil || !matches(current, found, old) {
+		return false, err
+	}
+	return true, s.Set(key, value, ttl)
+}
+
+// Increment is implementation of ThrottleStore
+func (s *DiskStore) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	current, _, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	n := parseCounter(current) + delta
+	return n, s.Set(key, []byte(strconv.FormatInt(n, 10)), ttl)
+}
+
+// lock serializes the read-modify-write of CompareAndSwap and Increment
+func (s *DiskStore) lock() (unlock func(), err error) {
+	s.mu.Lock()
+	// the directory may have been removed after NewDiskStore, eg. by a tmp cleaner
+	if err := os.MkdirAll(s.directory, os.ModePerm); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(s.directory, diskLockFile), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		// without the lock file, eg. on a read-only directory, only the goroutines of this process are serialized
+		return s.mu.Unlock, nil
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile(lock)
+		lock.Close()
+		s.mu.Unlock()
+	}, nil
+}
+
+// parseCounter reads the value of a counter, missing or invalid values are 0
+func parseCounter(value []byte) int64 {
+	n, _ := strconv.ParseInt(string(value), 10, 64)
+	return n
+}
+
+// matches tells if the current value of a key is old, a nil old matches a missing key
+func matches(current []byte, found bool, old []byte) bool {
+	if old == nil {
+		return !found
+	}
+	return found && bytes.Equal(current, old)
+}
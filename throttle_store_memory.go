@@ -0,0 +1,191 @@
+package alertnotification
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a process local ThrottleStore.
+// Expired keys are removed by a janitor and the least recently used keys are evicted beyond MaxEntries
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List // most recently used first
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero for ever
+}
+
+var (
+	envMemoryStoreOnce sync.Once
+	envMemoryStore     *MemoryStore
+)
+
+// NewMemoryStore creates the store, maxEntries 0 is unbounded and janitorInterval 0 disables the janitor
+func NewMemoryStore(maxEntries int, janitorInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		stop:       make(chan struct{}),
+	}
+	if janitorInterval > 0 {
+		go s.janitor(janitorInterval)
+	}
+	return s
+}
+
+// memoryStoreFromEnv is the store of THROTTLE_STORE=memory, shared by all throttlers of the process
+func memoryStoreFromEnv() *MemoryStore {
+	envMemoryStoreOnce.Do(func() {
+		envMemoryStore = NewMemoryStore(
+			getEnvInt("THROTTLE_MEMORY_MAX_ENTRIES", 10000),
+			time.Duration(getEnvInt("THROTTLE_MEMORY_JANITOR_SECONDS", 60))*time.Second,
+		)
+	})
+	return envMemoryStore
+}
+
+// Get is implementation of ThrottleStore
+func (s *MemoryStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := e.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		s.remove(e)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(e)
+	return entry.value, true, nil
+}
+
+// Set is implementation of ThrottleStore
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, value, ttl)
+	return nil
+}
+
+// CompareAndSwap is implementation of ThrottleStore
+func (s *MemoryStore) CompareAndSwap(key string, old []byte, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	found := ok && !e.Value.(*memoryEntry).expired(time.Now())
+	var current []byte
+	if found {
+		current = e.Value.(*memoryEntry).value
+	}
+	if !matches(current, found, old) {
+		return false, nil
+	}
+	s.set(key, value, ttl)
+	return true, nil
+}
+
+// Increment is implementation of ThrottleStore
+func (s *MemoryStore) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := delta
+	if e, ok := s.entries[key]; ok && !e.Value.(*memoryEntry).expired(time.Now()) {
+		n += parseCounter(e.Value.(*memoryEntry).value)
+	}
+	s.set(key, []byte(strconv.FormatInt(n, 10)), ttl)
+	return n, nil
+}
+
+func (s *MemoryStore) set(key string, value []byte, ttl time.Duration) {
+	entry := &memoryEntry{key: key, value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	if e, ok := s.entries[key]; ok {
+		e.Value = entry
+		s.lru.MoveToFront(e)
+		return
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+}
+
+// Delete is implementation of ThrottleStore
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+// Clean is implementation of ThrottleStore
+func (s *MemoryStore) Clean() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = map[string]*list.Element{}
+	s.lru.Init()
+	return nil
+}
+
+// Len returns the number of keys, including expired ones not collected yet
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Close stops the janitor
+func (s *MemoryStore) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.removeExpired(now)
+		}
+	}
+}
+
+func (s *MemoryStore) removeExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for e := s.lru.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*memoryEntry).expired(now) {
+			s.remove(e)
+		}
+		e = next
+	}
+}
+
+func (s *MemoryStore) remove(e *list.Element) {
+	s.lru.Remove(e)
+	delete(s.entries, e.Value.(*memoryEntry).key)
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
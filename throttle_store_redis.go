@@ -0,0 +1,151 @@
+package alertnotification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is a ThrottleStore shared by all replicas of an application
+type RedisStore struct {
+	Client  redis.UniversalClient
+	Prefix  string        // prepended to all keys
+	Timeout time.Duration // of each command, 2s when 0
+}
+
+var (
+	envRedisStoreMu sync.Mutex
+	envRedisStore   *RedisStore
+)
+
+// NewRedisStore creates the store with keys prefixed by "alertnotification:{APP_NAME}:{APP_ENV}:"
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{
+		Client: client,
+		Prefix: fmt.Sprintf("alertnotification:%v:%v:", os.Getenv("APP_NAME"), os.Getenv("APP_ENV")),
+	}
+}
+
+// redisStoreFromEnv is the store of THROTTLE_STORE=redis, connected to THROTTLE_REDIS_URL
+func redisStoreFromEnv() (*RedisStore, error) {
+	envRedisStoreMu.Lock()
+	defer envRedisStoreMu.Unlock()
+	if envRedisStore != nil {
+		return envRedisStore, nil
+	}
+	redisURL := os.Getenv("THROTTLE_REDIS_URL")
+	if redisURL == "" {
+		return nil, errors.New("THROTTLE_REDIS_URL is not set in the environment")
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	envRedisStore = NewRedisStore(redis.NewClient(opts))
+	if prefix := os.Getenv("THROTTLE_REDIS_PREFIX"); prefix != "" {
+		envRedisStore.Prefix = prefix
+	}
+	return envRedisStore, nil
+}
+
+func (s *RedisStore) context() (context.Context, context.CancelFunc) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// Get is implementation of ThrottleStore
+func (s *RedisStore) Get(key string) ([]byte, bool, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	value, err := s.Client.Get(ctx, s.Prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set is implementation of ThrottleStore
+func (s *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	return s.Client.Set(ctx, s.Prefix+key, value, ttl).Err()
+}
+
+// compareAndSwapScript sets KEYS[1] to ARGV[2], with PX ARGV[3] when positive, if it is equal to ARGV[1]
+var compareAndSwapScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// CompareAndSwap is implementation of ThrottleStore, with SET NX PX when old is nil and a Lua script otherwise
+func (s *RedisStore) CompareAndSwap(key string, old []byte, value []byte, ttl time.Duration) (bool, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	if old == nil {
+		return s.Client.SetNX(ctx, s.Prefix+key, value, ttl).Result()
+	}
+	px := ttl.Milliseconds()
+	if ttl > 0 && px == 0 {
+		px = 1
+	}
+	swapped, err := compareAndSwapScript.Run(ctx, s.Client, []string{s.Prefix + key}, old, value, px).Int()
+	return swapped == 1, err
+}
+
+// Increment is implementation of ThrottleStore, with INCRBY and PEXPIRE in a transaction
+func (s *RedisStore) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	var incr *redis.IntCmd
+	_, err := s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(ctx, s.Prefix+key, delta)
+		if ttl > 0 {
+			pipe.PExpire(ctx, s.Prefix+key, ttl)
+		} else {
+			pipe.Persist(ctx, s.Prefix+key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Delete is implementation of ThrottleStore
+func (s *RedisStore) Delete(key string) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	return s.Client.Del(ctx, s.Prefix+key).Err()
+}
+
+// Clean is implementation of ThrottleStore, it removes the keys of Prefix only
+func (s *RedisStore) Clean() error {
+	ctx, cancel := s.context()
+	defer cancel()
+	iter := s.Client.Scan(ctx, 0, s.Prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.Client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
@@ -0,0 +1,99 @@
+package alertnotification
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	t.Setenv("APP_NAME", "golang")
+	t.Setenv("APP_ENV", "test")
+	return NewRedisStore(client), mr
+}
+
+func TestRedisStore(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	if err := s.Set("key", []byte("value"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := mr.Get("alertnotification:golang:test:key"); err != nil || got != "value" {
+		t.Errorf("RedisStore.Set() stored %q, %v, want prefixed key", got, err)
+	}
+	if got, found, err := s.Get("key"); err != nil || !found || string(got) != "value" {
+		t.Errorf("RedisStore.Get() = %q, %v, %v", got, found, err)
+	}
+
+	mr.FastForward(time.Minute)
+	if _, found, _ := s.Get("key"); found {
+		t.Errorf("RedisStore.Get() found expired key")
+	}
+
+	casTests := []struct {
+		old         []byte
+		value       string
+		wantSwapped bool
+	}{
+		{old: nil, value: "1", wantSwapped: true},
+		{old: nil, value: "2", wantSwapped: false},
+		{old: []byte("2"), value: "3", wantSwapped: false},
+		{old: []byte("1"), value: "3", wantSwapped: true},
+	}
+	for _, tt := range casTests {
+		swapped, err := s.CompareAndSwap("cas", tt.old, []byte(tt.value), time.Minute)
+		if err != nil || swapped != tt.wantSwapped {
+			t.Errorf("RedisStore.CompareAndSwap(%q, %q) = %v, %v, want %v", tt.old, tt.value, swapped, err, tt.wantSwapped)
+		}
+	}
+	if ttl := mr.TTL("alertnotification:golang:test:cas"); ttl != time.Minute {
+		t.Errorf("RedisStore.CompareAndSwap() TTL = %v, want 1m", ttl)
+	}
+
+	testIncrement(t, s, mr.FastForward)
+	if ttl := mr.TTL("alertnotification:golang:test:concurrent"); ttl != time.Hour {
+		t.Errorf("RedisStore.Increment() TTL = %v, want 1h", ttl)
+	}
+
+	mr.Set("other-app:key", "kept")
+	if err := s.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != "other-app:key" {
+		t.Errorf("RedisStore.Clean() left keys %v, want only the other prefix", keys)
+	}
+}
+
+func TestRedisStore_replicas(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	ocError := errors.New("test_replicas")
+
+	// every replica has its own Throttler and client on the same Redis
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sent := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replica := NewRedisStore(redis.NewClient(&redis.Options{Addr: store.Client.(*redis.Client).Options().Addr}))
+			defer replica.Client.Close()
+			th := &Throttler{ThrottleDuration: 5 * time.Minute, Store: replica}
+			if !th.IsThrottledOrGraced(ocError) {
+				mu.Lock()
+				sent++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if sent != 1 {
+		t.Errorf("%v replicas sent the alert, want 1", sent)
+	}
+}
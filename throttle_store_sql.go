@@ -0,0 +1,226 @@
+package alertnotification
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLStore is a ThrottleStore in a database/sql table, for PostgreSQL or SQLite.
+// The driver is registered by the application, eg. with a blank import of github.com/lib/pq
+type SQLStore struct {
+	db       *sql.DB
+	table    string
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+var (
+	envSQLStoreMu sync.Mutex
+	envSQLStore   *SQLStore
+
+	sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// sqlMigrations creates the schema, the version of the table is the number of migrations applied
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS {table} (
+		throttle_key TEXT PRIMARY KEY,
+		throttle_value TEXT NOT NULL,
+		expires_at BIGINT NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS {table}_expires_at ON {table} (expires_at)`,
+	`ALTER TABLE {table} ADD COLUMN occurrences BIGINT NOT NULL DEFAULT 0`,
+}
+
+// NewSQLStore migrates table, default alert_throttle, and removes the expired rows every cleanupInterval.
+// cleanupInterval 0 disables the cleanup
+func NewSQLStore(db *sql.DB, table string, cleanupInterval time.Duration) (*SQLStore, error) {
+	if table == "" {
+		table = "alert_throttle"
+	}
+	if !sqlIdentifier.MatchString(table) {
+		return nil, errors.New("invalid throttle table name " + table)
+	}
+	s := &SQLStore{db: db, table: table, stop: make(chan struct{})}
+	if err := s.Migrate(); err != nil {
+		return nil, err
+	}
+	if cleanupInterval > 0 {
+		go s.cleanup(cleanupInterval)
+	}
+	return s, nil
+}
+
+// sqlStoreFromEnv is the store of THROTTLE_STORE=sql
+func sqlStoreFromEnv() (*SQLStore, error) {
+	envSQLStoreMu.Lock()
+	defer envSQLStoreMu.Unlock()
+	if envSQLStore != nil {
+		return envSQLStore, nil
+	}
+	driver, dsn := os.Getenv("THROTTLE_SQL_DRIVER"), os.Getenv("THROTTLE_SQL_DSN")
+	if driver == "" || dsn == "" {
+		return nil, errors.New("THROTTLE_SQL_DRIVER and THROTTLE_SQL_DSN must be set in the environment")
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	cleanup := time.Duration(getEnvInt("THROTTLE_SQL_CLEANUP_SECONDS", 300)) * time.Second
+	s, err := NewSQLStore(db, os.Getenv("THROTTLE_SQL_TABLE"), cleanup)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	envSQLStore = s
+	return s, nil
+}
+
+// query replaces {table} by the table of the store
+func (s *SQLStore) query(q string) string {
+	return strings.ReplaceAll(q, "{table}", s.table)
+}
+
+// Migrate applies the migrations missing from the database, it is called by NewSQLStore
+func (s *SQLStore) Migrate() error {
+	versions := s.table + "_schema"
+	if _, err := s.db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (version INTEGER NOT NULL)", versions)); err != nil {
+		return err
+	}
+	var version int
+	if err := s.db.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %v", versions)).Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(sqlMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(s.query(sqlMigrations[version])); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("throttle table migration %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %v (version) VALUES ($1)", versions), version+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get is implementation of ThrottleStore
+func (s *SQLStore) Get(key string) ([]byte, bool, error) {
+	var value string
+	var expires int64
+	err := s.db.QueryRow(s.query("SELECT throttle_value, expires_at FROM {table} WHERE throttle_key = $1"), key).Scan(&value, &expires)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if expires != 0 && time.Now().UnixNano() >= expires {
+		return nil, false, nil
+	}
+	return []byte(value), true, nil
+}
+
+// Set is implementation of ThrottleStore
+func (s *SQLStore) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := s.db.Exec(s.query(`INSERT INTO {table} (throttle_key, throttle_value, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (throttle_key) DO UPDATE SET throttle_value = excluded.throttle_value, expires_at = excluded.expires_at`),
+		key, string(value), expiresAt(ttl))
+	return err
+}
+
+// CompareAndSwap is implementation of ThrottleStore
+func (s *SQLStore) CompareAndSwap(key string, old []byte, value []byte, ttl time.Duration) (bool, error) {
+	var res sql.Result
+	var err error
+	if old == nil {
+		res, err = s.db.Exec(s.query(`INSERT INTO {table} (throttle_key, throttle_value, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (throttle_key) DO UPDATE SET throttle_value = excluded.throttle_value, expires_at = excluded.expires_at
+			WHERE {table}.expires_at <> 0 AND {table}.expires_at <= $4`),
+			key, string(value), expiresAt(ttl), time.Now().UnixNano())
+	} else {
+		res, err = s.db.Exec(s.query(`UPDATE {table} SET throttle_value = $1, expires_at = $2
+			WHERE throttle_key = $3 AND throttle_value = $4 AND (expires_at = 0 OR expires_at > $5)`),
+			string(value), expiresAt(ttl), key, string(old), time.Now().UnixNano())
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Increment is implementation of ThrottleStore, the counter is the occurrences column, copied to throttle_value for Get
+func (s *SQLStore) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	var occurrences int64
+	err := s.db.QueryRow(s.query(`INSERT INTO {table} (throttle_key, throttle_value, occurrences, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (throttle_key) DO UPDATE SET
+			occurrences = CASE WHEN {table}.expires_at <> 0 AND {table}.expires_at <= $5 THEN excluded.occurrences ELSE {table}.occurrences + excluded.occurrences END,
+			throttle_value = CAST(CASE WHEN {table}.expires_at <> 0 AND {table}.expires_at <= $5 THEN excluded.occurrences ELSE {table}.occurrences + excluded.occurrences END AS TEXT),
+			expires_at = excluded.expires_at
+		RETURNING occurrences`),
+		key, strconv.FormatInt(delta, 10), delta, expiresAt(ttl), time.Now().UnixNano()).Scan(&occurrences)
+	return occurrences, err
+}
+
+// Delete is implementation of ThrottleStore
+func (s *SQLStore) Delete(key string) error {
+	_, err := s.db.Exec(s.query("DELETE FROM {table} WHERE throttle_key = $1"), key)
+	return err
+}
+
+// Clean is implementation of ThrottleStore
+func (s *SQLStore) Clean() error {
+	_, err := s.db.Exec(s.query("DELETE FROM {table}"))
+	return err
+}
+
+// DeleteExpired removes the expired rows and returns how many were removed
+func (s *SQLStore) DeleteExpired() (int64, error) {
+	res, err := s.db.Exec(s.query("DELETE FROM {table} WHERE expires_at <> 0 AND expires_at <= $1"), time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Close stops the cleanup, the database is left open
+func (s *SQLStore) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *SQLStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.DeleteExpired()
+		}
+	}
+}
+
+// expiresAt is the unix nano time after ttl, 0 for ever
+func expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
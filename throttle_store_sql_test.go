@@ -0,0 +1,101 @@
+package alertnotification
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestSQLStore(t *testing.T) (*SQLStore, *sql.DB) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "throttle.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewSQLStore(db, "", 0)
+	if err != nil {
+		t.Fatalf("NewSQLStore() error = %v", err)
+	}
+	return s, db
+}
+
+func TestSQLStore(t *testing.T) {
+	s, db := newTestSQLStore(t)
+	if _, err := NewSQLStore(db, "", 0); err != nil {
+		t.Fatalf("NewSQLStore() on migrated table error = %v", err)
+	}
+	var version int
+	db.QueryRow("SELECT MAX(version) FROM alert_throttle_schema").Scan(&version)
+	if version != len(sqlMigrations) {
+		t.Errorf("schema version = %v, want %v", version, len(sqlMigrations))
+	}
+
+	s.Set("key", []byte("v1"), time.Hour)
+	s.Set("key", []byte("v2"), time.Hour)
+	s.Set("expired", []byte("v3"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if got, found, err := s.Get("key"); err != nil || !found || string(got) != "v2" {
+		t.Errorf("SQLStore.Get() = %q, %v, %v, want upserted value", got, found, err)
+	}
+	if _, found, _ := s.Get("expired"); found {
+		t.Errorf("SQLStore.Get() found expired key")
+	}
+
+	casTests := []struct {
+		old         []byte
+		value       string
+		wantSwapped bool
+	}{
+		{old: nil, value: "claim", wantSwapped: true}, // expired counts as missing
+		{old: nil, value: "again", wantSwapped: false},
+		{old: []byte("other"), value: "again", wantSwapped: false},
+		{old: []byte("claim"), value: "again", wantSwapped: true},
+	}
+	for _, tt := range casTests {
+		swapped, err := s.CompareAndSwap("expired", tt.old, []byte(tt.value), time.Hour)
+		if err != nil || swapped != tt.wantSwapped {
+			t.Errorf("SQLStore.CompareAndSwap(%q, %q) = %v, %v, want %v", tt.old, tt.value, swapped, err, tt.wantSwapped)
+		}
+	}
+
+	testIncrement(t, s, time.Sleep)
+
+	s.Set("old", []byte("v"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if n, err := s.DeleteExpired(); err != nil || n != 1 {
+		t.Errorf("SQLStore.DeleteExpired() = %v, %v, want 1", n, err)
+	}
+	s.Delete("key")
+	if _, found, _ := s.Get("key"); found {
+		t.Errorf("SQLStore.Get() found deleted key")
+	}
+	if err := s.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Get("expired"); found {
+		t.Errorf("SQLStore.Get() found key after Clean()")
+	}
+}
+
+func TestSQLStore_throttler(t *testing.T) {
+	s, _ := newTestSQLStore(t)
+	th := &Throttler{ThrottleDuration: 5 * time.Minute, Store: s}
+	ocError := errors.New("test_sql")
+	if th.IsThrottledOrGraced(ocError) || !th.IsThrottledOrGraced(ocError) {
+		t.Errorf("Throttler with SQLStore did not throttle the second occurrence")
+	}
+	s.Delete(Fingerprint(ocError))
+	if throttled, stats := th.check(Fingerprint(ocError), th.ThrottleDuration); throttled || stats.Suppressed != 1 {
+		t.Errorf("Throttler.check() with SQLStore = %v, %+v, want the suppressed occurrence", throttled, stats)
+	}
+}
+
+func TestNewSQLStore_invalidTable(t *testing.T) {
+	if _, err := NewSQLStore(nil, "alerts; DROP TABLE users", 0); err == nil {
+		t.Errorf("NewSQLStore() with invalid table error = nil")
+	}
+}
@@ -0,0 +1,298 @@
+package alertnotification
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiskStore(t *testing.T) {
+	s, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("forever", []byte("v1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("expiring", []byte("v2"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("expired", []byte("v3"), time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	tests := []struct {
+		key       string
+		want      string
+		wantFound bool
+	}{
+		{key: "forever", want: "v1", wantFound: true},
+		{key: "expiring", want: "v2", wantFound: true},
+		{key: "expired"},
+		{key: "missing"},
+	}
+	for _, tt := range tests {
+		got, found, err := s.Get(tt.key)
+		if err != nil || found != tt.wantFound || string(got) != tt.want {
+			t.Errorf("DiskStore.Get(%v) = %q, %v, %v, want %q, %v", tt.key, got, found, err, tt.want, tt.wantFound)
+		}
+	}
+
+	if err := s.Delete("forever"); err != nil {
+		t.Errorf("DiskStore.Delete() error = %v", err)
+	}
+	if err := s.Delete("missing"); err != nil {
+		t.Errorf("DiskStore.Delete() of missing key error = %v", err)
+	}
+	if _, found, _ := s.Get("forever"); found {
+		t.Errorf("DiskStore.Get() found deleted key")
+	}
+	if err := s.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Get("expiring"); found {
+		t.Errorf("DiskStore.Get() found key after Clean()")
+	}
+	testIncrement(t, s, time.Sleep)
+}
+
+// testIncrement checks Increment of store, wait lets time pass for the store
+func testIncrement(t *testing.T, store ThrottleStore, wait func(time.Duration)) {
+	t.Helper()
+	for _, want := range []int64{1, 2, 3} {
+		if got, err := store.Increment("count", 1, time.Hour); err != nil || got != want {
+			t.Errorf("Increment() = %v, %v, want %v", got, err, want)
+		}
+	}
+	if got, err := store.Increment("count", -3, time.Hour); err != nil || got != 0 {
+		t.Errorf("Increment(-3) = %v, %v, want 0", got, err)
+	}
+	if got, found, err := store.Get("count"); err != nil || !found || string(got) != "0" {
+		t.Errorf("Get() of counter = %q, %v, %v, want 0", got, found, err)
+	}
+
+	store.Increment("expiring", 5, time.Millisecond)
+	wait(10 * time.Millisecond)
+	if got, err := store.Increment("expiring", 1, time.Hour); err != nil || got != 1 {
+		t.Errorf("Increment() of expired counter = %v, %v, want 1", got, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Increment("concurrent", 1, time.Hour); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, err := store.Increment("concurrent", 0, time.Hour); err != nil || got != 50 {
+		t.Errorf("Increment() from 50 goroutines = %v, %v, want 50", got, err)
+	}
+}
+
+func TestThrottler_Store(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	th := &Throttler{CacheOpt: "/nonexistent/read-only", ThrottleDuration: 5 * time.Minute, Store: store}
+	ocError := errors.New("test_store")
+	if th.IsThrottledOrGraced(ocError) {
+		t.Errorf("Throttler.IsThrottledOrGraced() first call = true, want false")
+	}
+	if !th.IsThrottledOrGraced(ocError) {
+		t.Errorf("Throttler.IsThrottledOrGraced() second call = false, want true")
+	}
+	if _, found, _ := store.Get(Fingerprint(ocError)); !found {
+		t.Errorf("Throttler did not use its Store")
+	}
+}
+
+func TestSetThrottleStore(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	SetThrottleStore(store)
+	defer SetThrottleStore(nil)
+	t.Setenv("THROTTLE_ENABLED", "true")
+	t.Setenv("THROTTLE_GRACE_SECONDS", "0")
+	t.Setenv("THROTTLE_DISKCACHE_DIR", "/nonexistent/read-only")
+
+	a := &Alert{Error: errors.New("test_default_store")}
+	if !a.shouldAlert() || a.shouldAlert() {
+		t.Errorf("Alert.shouldAlert() did not throttle with the default store")
+	}
+	if _, found, _ := store.Get(a.fingerprint()); !found {
+		t.Errorf("Alert.shouldAlert() did not use the default store")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(2, 0)
+	s.Set("a", []byte("1"), 0)
+	s.Set("b", []byte("2"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, found, _ := s.Get("b"); found {
+		t.Errorf("MemoryStore.Get() found expired key")
+	}
+
+	s.Set("b", []byte("2"), time.Hour)
+	s.Get("a") // b is now the least recently used
+	s.Set("c", []byte("3"), 0)
+	if _, found, _ := s.Get("b"); found {
+		t.Errorf("MemoryStore did not evict the least recently used key")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, found, _ := s.Get(key); !found {
+			t.Errorf("MemoryStore.Get(%v) not found", key)
+		}
+	}
+	s.Delete("a")
+	if s.Len() != 1 {
+		t.Errorf("MemoryStore.Len() = %v, want 1", s.Len())
+	}
+	testIncrement(t, NewMemoryStore(0, 0), time.Sleep)
+}
+
+func TestMemoryStore_janitor(t *testing.T) {
+	s := NewMemoryStore(0, time.Millisecond)
+	defer s.Close()
+	s.Set("expiring", []byte("1"), time.Millisecond)
+	s.Set("forever", []byte("2"), 0)
+	deadline := time.Now().Add(time.Second)
+	for s.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if s.Len() != 1 {
+		t.Errorf("MemoryStore.Len() = %v after janitor, want 1", s.Len())
+	}
+}
+
+func TestDiskStore_CompareAndSwap_processes(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	swaps := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// a store per goroutine only shares the directory, like separate processes
+			s, err := NewDiskStore(dir)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			swapped, err := s.CompareAndSwap("key", nil, []byte("value"), time.Minute)
+			if err != nil {
+				t.Error(err)
+			}
+			if swapped {
+				mu.Lock()
+				swaps++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if swaps != 1 {
+		t.Errorf("DiskStore.CompareAndSwap() succeeded %v times, want 1", swaps)
+	}
+}
+
+func TestDiskStore_CompareAndSwap_lockFile(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "cache")
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped, err := s.CompareAndSwap("key", nil, []byte("value"), time.Minute); !swapped || err != nil {
+		t.Fatalf("DiskStore.CompareAndSwap() = %v, %v", swapped, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, diskLockFile)); err != nil {
+		t.Errorf("DiskStore.CompareAndSwap() lock file is not in the directory: %v", err)
+	}
+	if entries, _ := os.ReadDir(parent); len(entries) != 1 {
+		t.Errorf("DiskStore.CompareAndSwap() wrote next to the directory: %v", entries)
+	}
+
+	if err := s.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	// the lock file cannot be created, the process-wide lock is used
+	if err := os.Mkdir(filepath.Join(dir, diskLockFile), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if swapped, err := s.CompareAndSwap("key", nil, []byte("value"), time.Minute); !swapped || err != nil {
+		t.Errorf("DiskStore.CompareAndSwap() without lock file = %v, %v", swapped, err)
+	}
+}
+
+func TestDiskStore_removedDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	s, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	th := &Throttler{ThrottleDuration: time.Minute, Store: s}
+	if throttled, _ := th.check("key", th.ThrottleDuration); throttled {
+		t.Fatalf("Throttler.check() throttled the first occurrence")
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if throttled, _ := th.check("key", th.ThrottleDuration); throttled {
+		t.Fatalf("Throttler.check() throttled after the directory was removed")
+	}
+	if throttled, _ := th.check("key", th.ThrottleDuration); !throttled {
+		t.Errorf("Throttler.check() did not throttle once the directory was created again")
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("other", []byte("value"), 0); err != nil {
+		t.Errorf("DiskStore.Set() after the directory was removed error = %v", err)
+	}
+}
+
+func TestAlert_Notify_concurrent(t *testing.T) {
+	var mu sync.Mutex
+	cards := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		cards++
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	resetHTTPClient(t)
+	t.Setenv("THROTTLE_ENABLED", "true")
+	t.Setenv("THROTTLE_DURATION", "5")
+	t.Setenv("THROTTLE_GRACE_SECONDS", "0")
+	t.Setenv("THROTTLE_DISKCACHE_DIR", t.TempDir())
+	t.Setenv("EMAIL_ALERT_ENABLED", "")
+	t.Setenv("MS_GRAPH_ALERT_ENABLED", "")
+	t.Setenv("MS_TEAMS_ALERT_ENABLED", "true")
+	t.Setenv("MS_TEAMS_WEBHOOK", server.URL)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := NewAlert(errors.New("concurrent error"), nil)
+			if err := a.Notify(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if cards != 1 {
+		t.Errorf("Alert.Notify() from 100 goroutines sent %v cards, want 1", cards)
+	}
+}
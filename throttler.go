@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/GitbookIO/diskache"
@@ -11,9 +12,11 @@ import (
 
 // Throttler struct storing disckage directory and Throttling duration
 type Throttler struct {
-	CacheOpt         string
-	ThrottleDuration int
-	GraceDuration    int
+	CacheOpt          string
+	ThrottleDuration  time.Duration
+	GraceDuration     time.Duration
+	SeverityDurations map[Severity]time.Duration // throttle duration of the alerts of a severity, instead of ThrottleDuration
+	Store             ThrottleStore              // disk store of CacheOpt when nil
 }
 
 // ErrorOccurrence store error time and error
@@ -27,23 +30,35 @@ func NewThrottler() Throttler {
 
 	t := Throttler{
 		CacheOpt:         fmt.Sprintf("/tmp/cache/%v_throttler_disk_cache", os.Getenv("APP_NAME")),
-		ThrottleDuration: 5, // default 5mn
-		GraceDuration:    0, // default 0sc
+		ThrottleDuration: 5 * time.Minute, // default 5mn
+		GraceDuration:    0,               // default 0sc
 	}
 	if len(os.Getenv("THROTTLE_DURATION")) != 0 {
-		duration, err := strconv.Atoi(os.Getenv("THROTTLE_DURATION"))
+		duration, err := parseDuration(os.Getenv("THROTTLE_DURATION"), time.Minute)
 		if err != nil {
 			return t
 		}
 		t.ThrottleDuration = duration
 	}
 	if len(os.Getenv("THROTTLE_GRACE_SECONDS")) != 0 {
-		grace, err := strconv.Atoi(os.Getenv("THROTTLE_GRACE_SECONDS"))
+		grace, err := parseDuration(os.Getenv("THROTTLE_GRACE_SECONDS"), time.Second)
 		if err != nil {
 			return t
 		}
 		t.GraceDuration = grace
 	}
+	for _, severity := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		key := "THROTTLE_DURATION_" + strings.ToUpper(string(severity))
+		if len(os.Getenv(key)) == 0 {
+			continue
+		}
+		if duration, err := parseDuration(os.Getenv(key), time.Minute); err == nil {
+			if t.SeverityDurations == nil {
+				t.SeverityDurations = map[Severity]time.Duration{}
+			}
+			t.SeverityDurations[severity] = duration
+		}
+	}
 
 	if len(os.Getenv("THROTTLE_DISKCACHE_DIR")) != 0 {
 		t.CacheOpt = os.Getenv("THROTTLE_DISKCACHE_DIR")
@@ -52,90 +67,148 @@ func NewThrottler() Throttler {
 	return t
 }
 
+// parseDuration reads durations like "90s" or "2h", and bare numbers in unit for the former integer settings
+func parseDuration(value string, unit time.Duration) (time.Duration, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return time.Duration(n) * unit, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// throttleDuration is the throttle duration of the alert: its own, the one of its severity or ThrottleDuration
+func (t *Throttler) throttleDuration(a *Alert) time.Duration {
+	if a.ThrottleDuration > 0 {
+		return a.ThrottleDuration
+	}
+	if d, ok := t.SeverityDurations[a.Severity]; ok {
+		return d
+	}
+	return t.ThrottleDuration
+}
+
 // IsThrottled checks if the error has been throttled. If not, throttle it
 func (t *Throttler) IsThrottledOrGraced(ocError error) bool {
-	dc, err := t.getDiskCache()
+	return t.isThrottledOrGraced(Fingerprint(ocError))
+}
+
+// isThrottledOrGraced checks the errors of fingerprint key
+func (t *Throttler) isThrottledOrGraced(key string) bool {
+	throttled, _ := t.check(key, t.ThrottleDuration)
+	return throttled
+}
+
+// check checks the errors of fingerprint key, counting the throttled occurrences.
+// When the error is not throttled, it returns the occurrences throttled since its last alert, cleared by clearSuppressed once it is sent
+func (t *Throttler) check(key string, throttle time.Duration) (bool, OccurrenceStats) {
+	store, err := t.store()
+	if err != nil {
+		return false, OccurrenceStats{}
+	}
+	if t.throttledOrGraced(store, key, throttle) {
+		if err := recordSuppressed(store, key, time.Now()); err != nil {
+			fmt.Println(err)
+		}
+		return true, OccurrenceStats{}
+	}
+	stats, err := readSuppressed(store, key)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return false, stats
+}
+
+func (t *Throttler) throttledOrGraced(store ThrottleStore, key string, throttle time.Duration) bool {
+	cachedThrottleTime, throttled, err := store.Get(key)
+	if err != nil {
+		return false
+	}
+	cachedDetectionTime, graced, err := store.Get(fmt.Sprintf("%v_detectionTime", key))
 	if err != nil {
 		return false
 	}
-	cachedThrottleTime, throttled := dc.Get(ocError.Error())
-	cachedDetectionTime, graced := dc.Get(fmt.Sprintf("%v_detectionTime", ocError.Error()))
 
-	throttleIsOver := isOverThrottleDuration(string(cachedThrottleTime), t.ThrottleDuration)
+	throttleIsOver := isOverThrottleDuration(string(cachedThrottleTime), throttle)
 	if throttled && !throttleIsOver {
 		// already throttled and not over throttling duration, do nothing
 		return true
 	}
 
-	if !graced || isOverGracePlusThrottleDuration(string(cachedDetectionTime), t.GraceDuration, t.ThrottleDuration) {
-		cachedDetectionTime = t.InitGrace(ocError)
+	if !graced || isOverGracePlusThrottleDuration(string(cachedDetectionTime), t.GraceDuration, throttle) {
+		cachedDetectionTime = t.initGrace(key, throttle)
 	}
 	if cachedDetectionTime != nil && !isOverGraceDuration(string(cachedDetectionTime), t.GraceDuration) {
 		// grace duration is not over yet, do nothing
 		return true
 	}
 
-	// if it has not throttled yet or over throttle duration, throttle it and return false to send notification
-	// Rethrottler will also renew the timestamp in the throttler cache.
-	if err = t.ThrottleError(ocError); err != nil {
+	// if it has not throttled yet or over throttle duration, throttle it and return false to send notification.
+	// Only the caller which replaces the throttle time it read sends, other goroutines or processes are throttled
+	var previous []byte
+	if throttled {
+		previous = cachedThrottleTime
+	}
+	now := []byte(time.Now().Format(time.RFC3339Nano))
+	swapped, err := store.CompareAndSwap(key, previous, now, t.ttl(throttle))
+	if err != nil {
 		return false
 	}
-	return false
+	return !swapped
 }
 
-func isOverGracePlusThrottleDuration(cachedTime string, graceDurationInSec int, throttleDurationInMin int) bool {
-	detectionTime, err := time.Parse(time.RFC3339, string(cachedTime))
+func isOverGracePlusThrottleDuration(cachedTime string, graceDuration time.Duration, throttleDuration time.Duration) bool {
+	detectionTime, err := time.Parse(time.RFC3339Nano, cachedTime)
 	if err != nil {
 		return false
 	}
-	now := time.Now()
-	diff := int(now.Sub(detectionTime).Seconds())
-	overallDurationInSec := graceDurationInSec + throttleDurationInMin*60
-	return diff >= overallDurationInSec
+	return time.Since(detectionTime) >= graceDuration+throttleDuration
 }
 
-func isOverGraceDuration(cachedTime string, graceDuration int) bool {
-	detectionTime, err := time.Parse(time.RFC3339, string(cachedTime))
+func isOverGraceDuration(cachedTime string, graceDuration time.Duration) bool {
+	detectionTime, err := time.Parse(time.RFC3339Nano, cachedTime)
 	if err != nil {
 		return false
 	}
-	now := time.Now()
-	diff := int(now.Sub(detectionTime).Seconds())
-	return diff >= graceDuration
+	return time.Since(detectionTime) >= graceDuration
 }
 
-func isOverThrottleDuration(cachedTime string, throttleDuration int) bool {
-	throttledTime, err := time.Parse(time.RFC3339, string(cachedTime))
+func isOverThrottleDuration(cachedTime string, throttleDuration time.Duration) bool {
+	throttledTime, err := time.Parse(time.RFC3339Nano, cachedTime)
 	if err != nil {
 		return false
 	}
-	now := time.Now()
-	diff := int(now.Sub(throttledTime).Minutes())
-	return diff >= throttleDuration
+	return time.Since(throttledTime) >= throttleDuration
 }
 
 // ThrottleError throttle the alert within the limited duration
 func (t *Throttler) ThrottleError(errObj error) error {
-	dc, err := t.getDiskCache()
+	return t.throttle(Fingerprint(errObj))
+}
+
+func (t *Throttler) throttle(key string) error {
+	store, err := t.store()
 	if err != nil {
 		return err
 	}
 
-	now := time.Now().Format(time.RFC3339)
-	err = dc.Set(errObj.Error(), []byte(now))
+	now := time.Now().Format(time.RFC3339Nano)
+	err = store.Set(key, []byte(now), t.ttl(t.ThrottleDuration))
 
 	return err
 }
 
 // ThrottleError throttle the alert within the limited duration
 func (t *Throttler) InitGrace(errObj error) []byte {
-	dc, err := t.getDiskCache()
+	return t.initGrace(Fingerprint(errObj), t.ThrottleDuration)
+}
+
+func (t *Throttler) initGrace(key string, throttle time.Duration) []byte {
+	store, err := t.store()
 	if err != nil {
 		return nil
 	}
-	now := time.Now().Format(time.RFC3339)
+	now := time.Now().Format(time.RFC3339Nano)
 	cachedDetectionTime := []byte(now)
-	err = dc.Set(fmt.Sprintf("%v_detectionTime", errObj.Error()), cachedDetectionTime)
+	err = store.Set(fmt.Sprintf("%v_detectionTime", key), cachedDetectionTime, t.ttl(throttle))
 	if err != nil {
 		return nil
 	}
@@ -145,14 +218,19 @@ func (t *Throttler) InitGrace(errObj error) []byte {
 
 // CleanThrottlingCache clean all the diskcache in throttling cache directory
 func (t *Throttler) CleanThrottlingCache() (err error) {
-	dc, err := t.getDiskCache()
+	store, err := t.store()
 	if err != nil {
 		return err
 	}
-	err = dc.Clean()
+	err = store.Clean()
 	return err
 }
 
+// ttl is how long the throttling keys are useful, after the grace and throttle durations
+func (t *Throttler) ttl(throttle time.Duration) time.Duration {
+	return throttle + t.GraceDuration
+}
+
 func (t *Throttler) getDiskCache() (*diskache.Diskache, error) {
 	opts := diskache.Opts{
 		Directory: t.CacheOpt,
@@ -20,7 +20,7 @@ func TestNewThrottler(t *testing.T) {
 			name: "default",
 			want: Throttler{
 				CacheOpt:         fmt.Sprintf("/tmp/cache/%v_throttler_disk_cache", os.Getenv("APP_NAME")),
-				ThrottleDuration: 5,
+				ThrottleDuration: 5 * time.Minute,
 				GraceDuration:    0,
 			},
 		},
@@ -28,15 +28,15 @@ func TestNewThrottler(t *testing.T) {
 			name: "change duration",
 			want: Throttler{
 				CacheOpt:         fmt.Sprintf("/tmp/cache/%v_throttler_disk_cache", os.Getenv("APP_NAME")),
-				ThrottleDuration: 7,
-				GraceDuration:    5,
+				ThrottleDuration: 7 * time.Minute,
+				GraceDuration:    5 * time.Second,
 			},
 		},
 		{
 			name: "change both",
 			want: Throttler{
 				CacheOpt:         "new_cache_dir",
-				ThrottleDuration: 8,
+				ThrottleDuration: 8 * time.Minute,
 				GraceDuration:    0,
 			},
 		},
@@ -64,8 +64,8 @@ func TestNewThrottler(t *testing.T) {
 func TestThrottler_IsThrottledOrGraced(t *testing.T) {
 	type fields struct {
 		CacheOpt         string
-		ThrottleDuration int
-		GraceDuration    int
+		ThrottleDuration time.Duration
+		GraceDuration    time.Duration
 	}
 	type args struct {
 		ocError error
@@ -80,7 +80,7 @@ func TestThrottler_IsThrottledOrGraced(t *testing.T) {
 			name: "default",
 			fields: fields{
 				CacheOpt:         fmt.Sprintf("/tmp/cache/%v_throttler_disk_cache", os.Getenv("APP_NAME")),
-				ThrottleDuration: 5,
+				ThrottleDuration: 5 * time.Minute,
 				GraceDuration:    0,
 			},
 			args: args{
@@ -92,7 +92,7 @@ func TestThrottler_IsThrottledOrGraced(t *testing.T) {
 			name: "throttled_true",
 			fields: fields{
 				CacheOpt:         fmt.Sprintf("/tmp/cache/%v_throttler_disk_cache", os.Getenv("APP_NAME")),
-				ThrottleDuration: 5,
+				ThrottleDuration: 5 * time.Minute,
 				GraceDuration:    0,
 			},
 			args: args{
@@ -104,8 +104,8 @@ func TestThrottler_IsThrottledOrGraced(t *testing.T) {
 			name: "graced_true",
 			fields: fields{
 				CacheOpt:         fmt.Sprintf("/tmp/cache/%v_throttler_disk_cache", os.Getenv("APP_NAME")),
-				ThrottleDuration: 5,
-				GraceDuration:    25,
+				ThrottleDuration: 5 * time.Minute,
+				GraceDuration:    25 * time.Second,
 			},
 			args: args{
 				ocError: errors.New("test_throttling"),
@@ -140,8 +140,8 @@ func TestThrottler_IsThrottledOrGraced(t *testing.T) {
 func TestThrottler_ThrottleError(t *testing.T) {
 	type fields struct {
 		CacheOpt         string
-		ThrottleDuration int
-		GraceDuration    int
+		ThrottleDuration time.Duration
+		GraceDuration    time.Duration
 	}
 	type args struct {
 		errObj error
@@ -156,7 +156,7 @@ func TestThrottler_ThrottleError(t *testing.T) {
 			name: "default",
 			fields: fields{
 				CacheOpt:         fmt.Sprintf("/tmp/cache/%v_throttler_disk_cache", os.Getenv("APP_NAME")),
-				ThrottleDuration: 5,
+				ThrottleDuration: 5 * time.Minute,
 				GraceDuration:    0,
 			},
 			args: args{
@@ -168,7 +168,7 @@ func TestThrottler_ThrottleError(t *testing.T) {
 			name: "test_error",
 			fields: fields{
 				CacheOpt:         "/no_permission_dir",
-				ThrottleDuration: 5,
+				ThrottleDuration: 5 * time.Minute,
 				GraceDuration:    0,
 			},
 			args: args{
@@ -205,7 +205,7 @@ func TestThrottler_ThrottleError(t *testing.T) {
 func TestThrottler_getDiskCache(t *testing.T) {
 	type fields struct {
 		CacheOpt         string
-		ThrottleDuration int
+		ThrottleDuration time.Duration
 	}
 	cachePart := fmt.Sprintf("/tmp/cache/%v_throttler_disk_cache", os.Getenv("APP_NAME"))
 	opts := diskache.Opts{
@@ -227,7 +227,7 @@ func TestThrottler_getDiskCache(t *testing.T) {
 			name: "TestThrottler_getDiskCache_success",
 			fields: fields{
 				CacheOpt:         cachePart,
-				ThrottleDuration: 5,
+				ThrottleDuration: 5 * time.Minute,
 			},
 			want:    dc,
 			wantErr: false,
@@ -254,8 +254,8 @@ func TestThrottler_getDiskCache(t *testing.T) {
 func Test_isOverThrottleDuration(t *testing.T) {
 	type args struct {
 		cachedTime       string
-		throttleDuration int
-		graceDuration    int
+		throttleDuration time.Duration
+		graceDuration    time.Duration
 	}
 	tests := []struct {
 		name string
@@ -266,7 +266,7 @@ func Test_isOverThrottleDuration(t *testing.T) {
 			name: "Test_isOverThrottleDuration_true",
 			args: args{
 				cachedTime:       time.Now().Add(-3 * time.Minute).Format(time.RFC3339), // -3 minutes => pass 2 minutes durations
-				throttleDuration: 2,
+				throttleDuration: 2 * time.Minute,
 				graceDuration:    0,
 			},
 			want: true,
@@ -275,7 +275,7 @@ func Test_isOverThrottleDuration(t *testing.T) {
 			name: "Test_isOverThrottleDuration_false",
 			args: args{
 				cachedTime:       time.Now().Add(1 * time.Minute).Format(time.RFC3339), // 1 minute ahead of current < throtte duration 2
-				throttleDuration: 2,
+				throttleDuration: 2 * time.Minute,
 				graceDuration:    0,
 			},
 			want: false,
@@ -293,8 +293,8 @@ func Test_isOverThrottleDuration(t *testing.T) {
 func Test_isOverGraceDuration(t *testing.T) {
 	type args struct {
 		cachedTime       string
-		throttleDuration int
-		graceDuration    int
+		throttleDuration time.Duration
+		graceDuration    time.Duration
 	}
 	tests := []struct {
 		name string
@@ -306,7 +306,7 @@ func Test_isOverGraceDuration(t *testing.T) {
 			args: args{
 				cachedTime:       time.Now().Add(-5 * time.Second).Format(time.RFC3339), // 2 sec after grace duration is over
 				throttleDuration: 0,
-				graceDuration:    3,
+				graceDuration:    3 * time.Second,
 			},
 			want: true,
 		},
@@ -315,7 +315,7 @@ func Test_isOverGraceDuration(t *testing.T) {
 			args: args{
 				cachedTime:       time.Now().Add(2 * time.Second).Format(time.RFC3339), // still 8 sec left for grace duration
 				throttleDuration: 0,
-				graceDuration:    10,
+				graceDuration:    10 * time.Second,
 			},
 			want: false,
 		},
@@ -329,3 +329,96 @@ func Test_isOverGraceDuration(t *testing.T) {
 	}
 
 }
+
+func TestNewThrottler_durations(t *testing.T) {
+	t.Setenv("THROTTLE_DURATION", "90s")
+	t.Setenv("THROTTLE_GRACE_SECONDS", "2m")
+	t.Setenv("THROTTLE_DURATION_CRITICAL", "30s")
+	t.Setenv("THROTTLE_DURATION_WARNING", "2h")
+	t.Setenv("THROTTLE_DURATION_INFO", "")
+
+	th := NewThrottler()
+	if th.ThrottleDuration != 90*time.Second || th.GraceDuration != 2*time.Minute {
+		t.Errorf("NewThrottler() durations = %v, %v, want 1m30s, 2m0s", th.ThrottleDuration, th.GraceDuration)
+	}
+	want := map[Severity]time.Duration{SeverityCritical: 30 * time.Second, SeverityWarning: 2 * time.Hour}
+	if !reflect.DeepEqual(th.SeverityDurations, want) {
+		t.Errorf("NewThrottler() SeverityDurations = %v, want %v", th.SeverityDurations, want)
+	}
+
+	tests := []struct {
+		alert Alert
+		want  time.Duration
+	}{
+		{alert: Alert{Severity: SeverityCritical}, want: 30 * time.Second},
+		{alert: Alert{Severity: SeverityInfo}, want: 90 * time.Second},
+		{alert: Alert{Severity: SeverityCritical, ThrottleDuration: 10 * time.Second}, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := th.throttleDuration(&tt.alert); got != tt.want {
+			t.Errorf("Throttler.throttleDuration(%+v) = %v, want %v", tt.alert, got, tt.want)
+		}
+	}
+}
+
+func Test_parseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		unit    time.Duration
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "7", unit: time.Minute, want: 7 * time.Minute},
+		{value: "20", unit: time.Second, want: 20 * time.Second},
+		{value: "90s", unit: time.Minute, want: 90 * time.Second},
+		{value: "2h", unit: time.Second, want: 2 * time.Hour},
+		{value: "soon", unit: time.Minute, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.value, tt.unit)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func Test_isOverThrottleDuration_precise(t *testing.T) {
+	throttled := time.Now().Add(-80 * time.Second).Format(time.RFC3339Nano)
+	if isOverThrottleDuration(throttled, 90*time.Second) {
+		t.Errorf("isOverThrottleDuration() = true 80s after throttling for 90s")
+	}
+	if !isOverThrottleDuration(throttled, 75*time.Second) {
+		t.Errorf("isOverThrottleDuration() = false 80s after throttling for 75s")
+	}
+	// the windows end at the nanosecond, not at the next whole minute or second
+	throttled = time.Now().Add(-1500 * time.Millisecond).Format(time.RFC3339Nano)
+	if !isOverThrottleDuration(throttled, time.Second) {
+		t.Errorf("isOverThrottleDuration() = false 1.5s after throttling for 1s")
+	}
+}
+
+func TestAlert_Notify_severityDuration(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	SetThrottleStore(store)
+	defer SetThrottleStore(nil)
+	t.Setenv("THROTTLE_ENABLED", "true")
+	t.Setenv("THROTTLE_DURATION", "1h")
+	t.Setenv("THROTTLE_GRACE_SECONDS", "0")
+	t.Setenv("THROTTLE_DURATION_CRITICAL", "50ms")
+
+	critical := NewAlert(errors.New("critical error"), nil)
+	critical.Severity = SeverityCritical
+	warning := NewAlert(errors.New("warning error"), nil)
+	for _, a := range []*Alert{&critical, &warning} {
+		if !a.shouldAlert() {
+			t.Fatalf("Alert.shouldAlert() = false for the first %v", a.Error)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !critical.shouldAlert() {
+		t.Errorf("Alert.shouldAlert() = false for a critical error after its throttle duration")
+	}
+	if warning.shouldAlert() {
+		t.Errorf("Alert.shouldAlert() = true for a warning error within THROTTLE_DURATION")
+	}
+}
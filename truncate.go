@@ -0,0 +1,78 @@
+package alertnotification
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// elisionMarker replaces the lines removed by truncateMiddle
+func elisionMarker(lines int) string {
+	return fmt.Sprintf("\n... %d lines elided ...\n", lines)
+}
+
+// truncateMiddle shortens text to maxBytes keeping its first and last lines,
+// so both the error message and the outermost stack frames stay readable
+func truncateMiddle(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	budget := maxBytes - len(elisionMarker(len(lines)))
+	if budget <= 0 {
+		return truncateBytes(text, maxBytes)
+	}
+
+	head, tail := []string{}, []string{}
+	headSize, tailSize := 0, 0
+	i, j := 0, len(lines)-1
+	// take lines alternately from both ends, the head has priority
+	for i <= j {
+		progressed := false
+		if l := len(lines[i]) + 1; headSize+tailSize+l <= budget {
+			head = append(head, lines[i])
+			headSize += l
+			i++
+			progressed = true
+		}
+		if i > j {
+			break
+		}
+		if l := len(lines[j]) + 1; headSize+tailSize+l <= budget {
+			tail = append([]string{lines[j]}, tail...)
+			tailSize += l
+			j--
+			progressed = true
+		}
+		if !progressed {
+			break
+		}
+	}
+	if len(head) == 0 && len(tail) == 0 {
+		// a single huge line, cut inside it
+		return truncateBytes(text, maxBytes)
+	}
+	return strings.Join(head, "\n") + elisionMarker(j-i+1) + strings.Join(tail, "\n")
+}
+
+// truncateBytes keeps the beginning and the end of text without splitting UTF-8 characters
+func truncateBytes(text string, maxBytes int) string {
+	// the length of text bounds the width of the count
+	budget := maxBytes - len(bytesElisionMarker(len(text)))
+	if budget <= 0 {
+		return ""
+	}
+	head := budget / 2
+	for head > 0 && !utf8.RuneStart(text[head]) {
+		head--
+	}
+	tail := len(text) - (budget - head)
+	for tail < len(text) && !utf8.RuneStart(text[tail]) {
+		tail++
+	}
+	return text[:head] + bytesElisionMarker(tail-head) + text[tail:]
+}
+
+func bytesElisionMarker(n int) string {
+	return fmt.Sprintf(" ... %d bytes elided ... ", n)
+}
@@ -0,0 +1,44 @@
+package alertnotification
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func Test_truncateMiddle(t *testing.T) {
+	frames := []string{"panic: timeout"}
+	for i := 0; i < 200; i++ {
+		frames = append(frames, "\tgithub.com/example/app/handler.go:"+strings.Repeat("1", 3))
+	}
+	frames = append(frames, "main.main()")
+	stack := strings.Join(frames, "\n")
+
+	tests := []struct {
+		name     string
+		text     string
+		maxBytes int
+		want     []string
+	}{
+		{name: "fits", text: "short error", maxBytes: 100, want: []string{"short error"}},
+		{name: "stack", text: stack, maxBytes: 500, want: []string{"panic: timeout\n", "lines elided", "\nmain.main()"}},
+		{name: "single_line", text: strings.Repeat("エラー", 100), maxBytes: 120, want: []string{"エ", "bytes elided"}},
+		{name: "tiny_budget", text: stack, maxBytes: 10, want: []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateMiddle(tt.text, tt.maxBytes)
+			if len(got) > tt.maxBytes {
+				t.Errorf("truncateMiddle() length = %v, want <= %v", len(got), tt.maxBytes)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateMiddle() = %q is not valid UTF-8", got)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("truncateMiddle() = %q, want to contain %q", got, w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package alertnotification
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sleep waits between retries, replaced in tests
+var sleep = time.Sleep
+
+// WebhookError is returned by webhook notifiers when the request finally failed
+type WebhookError struct {
+	StatusCode int    // 0 when no response was received
+	Body       string // response body, at most 1MB
+	Message    string // description of the rejected response
+	Attempts   int
+	Permanent  bool // retrying the same request will not succeed
+	Err        error
+}
+
+func (e *WebhookError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("webhook request failed after %d attempts: %v", e.Attempts, e.Err)
+	}
+	return "unexpected response from webhook: " + e.Message
+}
+
+// Unwrap returns the network error
+func (e *WebhookError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent tells if err is a webhook failure which will not succeed on retry, eg. 400 Bad Request
+func IsPermanent(err error) bool {
+	var webhookErr *WebhookError
+	return errors.As(err, &webhookErr) && webhookErr.Permanent
+}
+
+// retryPolicy is exponential backoff with jitter between webhook attempts
+type retryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration // also caps Retry-After
+}
+
+// newRetryPolicy reads {prefix}_MAX_RETRIES, {prefix}_RETRY_BASE_MILLISECONDS and {prefix}_RETRY_MAX_WAIT_SECONDS
+func newRetryPolicy(prefix string) retryPolicy {
+	return retryPolicy{
+		MaxRetries: getEnvInt(prefix+"_MAX_RETRIES", 3),
+		BaseDelay:  time.Duration(getEnvInt(prefix+"_RETRY_BASE_MILLISECONDS", 500)) * time.Millisecond,
+		MaxDelay:   time.Duration(getEnvInt(prefix+"_RETRY_MAX_WAIT_SECONDS", 30)) * time.Second,
+	}
+}
+
+// getWebhookTimeout reads {prefix}_TIMEOUT_SECONDS, default 5sc
+func getWebhookTimeout(prefix string) time.Duration {
+	return time.Duration(getEnvInt(prefix+"_TIMEOUT_SECONDS", 5)) * time.Second
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if len(os.Getenv(key)) == 0 {
+		return defaultValue
+	}
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+// backoff is the wait before the retry number attempt, half fixed and half random
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << uint(attempt)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// webhookRequest is a JSON request to a webhook or an API
+type webhookRequest struct {
+	Method   string // POST when empty
+	URL      string
+	Body     []byte
+	Header   http.Header
+	Accepted func(statusCode int) bool
+	Describe func(statusCode int, body []byte) string // error message of a rejected response
+	Signer   *WebhookSigner                           // signs each attempt when set
+}
+
+// sendWebhookRequest sends the request, retrying network errors, 5xx and 429 responses.
+// It returns the body of the accepted response
+func sendWebhookRequest(client *http.Client, req webhookRequest, policy retryPolicy) ([]byte, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	var lastErr *WebhookError
+	var retryAfter time.Duration
+	for attempt := 0; attempt <= policy.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := policy.backoff(attempt - 1)
+			if retryAfter > 0 {
+				wait = retryAfter
+			}
+			if wait > policy.MaxDelay {
+				wait = policy.MaxDelay
+			}
+			sleep(wait)
+		}
+		retryAfter = 0
+
+		request, err := http.NewRequest(method, req.URL, bytes.NewReader(req.Body))
+		if err != nil {
+			return nil, &WebhookError{Attempts: attempt + 1, Permanent: true, Err: err}
+		}
+		for k, v := range req.Header {
+			request.Header[k] = v
+		}
+		request.Header.Set("Content-type", "application/json")
+		if req.Signer != nil {
+			req.Signer.Sign(request.Header, req.Body, time.Now())
+		}
+
+		resp, err := client.Do(request)
+		if err != nil {
+			lastErr = &WebhookError{Attempts: attempt + 1, Err: err}
+			continue
+		}
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+		resp.Body.Close()
+		if req.Accepted(resp.StatusCode) {
+			return respBody, err
+		}
+		if err != nil {
+			lastErr = &WebhookError{StatusCode: resp.StatusCode, Attempts: attempt + 1, Err: err}
+			continue
+		}
+		lastErr = &WebhookError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+			Message:    req.Describe(resp.StatusCode, respBody),
+			Attempts:   attempt + 1,
+			Permanent:  !isRetryableStatus(resp.StatusCode),
+		}
+		if lastErr.Permanent {
+			return nil, lastErr
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+	}
+	return nil, lastErr
+}
+
+// parseRetryAfter reads delay-seconds or HTTP-date, 0 when absent or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout || statusCode >= 500
+}
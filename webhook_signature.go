@@ -0,0 +1,112 @@
+package alertnotification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default headers of signed webhook requests
+const (
+	DefaultSignatureHeader = "X-Alert-Signature"
+	DefaultTimestampHeader = "X-Alert-Timestamp"
+)
+
+var (
+	// ErrInvalidSignature is returned when the signature does not match the body and timestamp
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrExpiredSignature is returned when the timestamp is outside of the tolerance, eg. a replayed request
+	ErrExpiredSignature = errors.New("webhook signature timestamp is outside of the tolerance")
+)
+
+// WebhookSigner signs webhook bodies with HMAC-SHA256.
+// The signature is "sha256=" followed by the hex HMAC of "{timestamp}.{body}", timestamp being unix seconds
+type WebhookSigner struct {
+	Secret          []byte
+	SignatureHeader string        // DefaultSignatureHeader when empty
+	TimestampHeader string        // DefaultTimestampHeader when empty
+	Tolerance       time.Duration // accepted clock skew and replay window of Verify, 5mn when 0
+}
+
+// NewWebhookSigner reads the signing config of a notifier from env.
+// {prefix}_HMAC_SECRET overrides WEBHOOK_HMAC_SECRET, nil when no secret is set
+func NewWebhookSigner(prefix string) *WebhookSigner {
+	secret := os.Getenv(prefix + "_HMAC_SECRET")
+	if secret == "" {
+		secret = os.Getenv("WEBHOOK_HMAC_SECRET")
+	}
+	if secret == "" {
+		return nil
+	}
+	return &WebhookSigner{
+		Secret:          []byte(secret),
+		SignatureHeader: os.Getenv("WEBHOOK_SIGNATURE_HEADER"),
+		TimestampHeader: os.Getenv("WEBHOOK_TIMESTAMP_HEADER"),
+		Tolerance:       time.Duration(getEnvInt("WEBHOOK_SIGNATURE_TOLERANCE_SECONDS", 300)) * time.Second,
+	}
+}
+
+// Sign sets the timestamp and signature headers of body
+func (s *WebhookSigner) Sign(header http.Header, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header.Set(s.timestampHeader(), timestamp)
+	header.Set(s.signatureHeader(), "sha256="+hex.EncodeToString(signature(s.Secret, timestamp, body)))
+}
+
+// Verify checks the headers of a request received with body
+func (s *WebhookSigner) Verify(header http.Header, body []byte) error {
+	tolerance := s.Tolerance
+	if tolerance == 0 {
+		tolerance = 5 * time.Minute
+	}
+	return VerifyWebhookSignature(s.Secret, header.Get(s.timestampHeader()), header.Get(s.signatureHeader()), body, tolerance)
+}
+
+func (s *WebhookSigner) signatureHeader() string {
+	if s.SignatureHeader == "" {
+		return DefaultSignatureHeader
+	}
+	return s.SignatureHeader
+}
+
+func (s *WebhookSigner) timestampHeader() string {
+	if s.TimestampHeader == "" {
+		return DefaultTimestampHeader
+	}
+	return s.TimestampHeader
+}
+
+// VerifyWebhookSignature checks a signature made by WebhookSigner.
+// Requests whose timestamp is further than tolerance from now are rejected to prevent replays
+func VerifyWebhookSignature(secret []byte, timestamp string, sig string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := time.Since(time.Unix(ts, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrExpiredSignature
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil || !strings.HasPrefix(sig, "sha256=") {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal(got, signature(secret, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signature(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
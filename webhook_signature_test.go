@@ -0,0 +1,76 @@
+package alertnotification
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWebhookSigner_Verify(t *testing.T) {
+	signer := &WebhookSigner{Secret: []byte("s3cret"), SignatureHeader: "X-Hub-Signature-256"}
+	body := []byte(`{"type":"message"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		modify  func(h http.Header) []byte
+		wantErr error
+	}{
+		{name: "valid", modify: func(h http.Header) []byte { return body }},
+		{name: "tampered_body", modify: func(h http.Header) []byte { return []byte(`{"type":"other"}`) }, wantErr: ErrInvalidSignature},
+		{
+			name: "tampered_timestamp",
+			modify: func(h http.Header) []byte {
+				h.Set(DefaultTimestampHeader, strconv.FormatInt(now.Unix()+1, 10))
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "replayed",
+			modify: func(h http.Header) []byte {
+				signer.Sign(h, body, now.Add(-time.Hour))
+				return body
+			},
+			wantErr: ErrExpiredSignature,
+		},
+		{name: "missing", modify: func(h http.Header) []byte { h.Del("X-Hub-Signature-256"); return body }, wantErr: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			signer.Sign(h, body, now)
+			received := tt.modify(h)
+			if err := signer.Verify(h, received); !errors.Is(err, tt.wantErr) {
+				t.Errorf("WebhookSigner.Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMsTeam_Send_signed(t *testing.T) {
+	resetHTTPClient(t)
+	t.Setenv("WEBHOOK_HMAC_SECRET", "s3cret")
+	t.Setenv("WEBHOOK_TIMESTAMP_HEADER", "X-Timestamp")
+	verifier := &WebhookSigner{Secret: []byte("s3cret"), TimestampHeader: "X-Timestamp"}
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = verifier.Verify(r.Header, body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	t.Setenv("MS_TEAMS_WEBHOOK", server.URL)
+
+	card := NewMsTeam(errors.New("signed error"), nil)
+	if err := card.Send(); err != nil {
+		t.Fatalf("MsTeam.Send() error = %v", err)
+	}
+	if verifyErr != nil {
+		t.Errorf("receiver could not verify the request: %v", verifyErr)
+	}
+}
@@ -0,0 +1,123 @@
+package alertnotification
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_sendWebhookRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		responses     []int
+		retryAfter    string
+		wantErr       bool
+		wantPermanent bool
+		wantAttempts  int
+		wantWaits     []time.Duration
+	}{
+		{name: "accepted", responses: []int{http.StatusAccepted}, wantAttempts: 1},
+		{name: "retry_5xx", responses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusAccepted}, wantAttempts: 3},
+		{
+			name:         "retry_after",
+			responses:    []int{http.StatusTooManyRequests, http.StatusAccepted},
+			retryAfter:   "7",
+			wantAttempts: 2,
+			wantWaits:    []time.Duration{7 * time.Second},
+		},
+		{
+			name:         "retry_after_capped",
+			responses:    []int{http.StatusTooManyRequests, http.StatusAccepted},
+			retryAfter:   "3600",
+			wantAttempts: 2,
+			wantWaits:    []time.Duration{10 * time.Second},
+		},
+		{name: "permanent", responses: []int{http.StatusBadRequest}, wantErr: true, wantPermanent: true, wantAttempts: 1},
+		{
+			name:         "exhausted",
+			responses:    []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			wantErr:      true,
+			wantAttempts: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.responses[attempts]
+				attempts++
+				if status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+				w.Write([]byte("status body"))
+			}))
+			defer server.Close()
+
+			waits := []time.Duration{}
+			sleep = func(d time.Duration) { waits = append(waits, d) }
+			defer func() { sleep = time.Sleep }()
+
+			policy := retryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second}
+			_, err := sendWebhookRequest(server.Client(), webhookRequest{
+				URL:      server.URL,
+				Body:     []byte(`{}`),
+				Accepted: func(statusCode int) bool { return statusCode == http.StatusAccepted },
+				Describe: func(statusCode int, body []byte) string { return string(body) },
+			}, policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendWebhookRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if IsPermanent(err) != tt.wantPermanent {
+				t.Errorf("IsPermanent() = %v, want %v", IsPermanent(err), tt.wantPermanent)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("sendWebhookRequest() attempts = %v, want %v", attempts, tt.wantAttempts)
+			}
+			if tt.wantWaits != nil && !reflect.DeepEqual(waits, tt.wantWaits) {
+				t.Errorf("sendWebhookRequest() waits = %v, want %v", waits, tt.wantWaits)
+			}
+			var webhookErr *WebhookError
+			if tt.wantErr && (!errors.As(err, &webhookErr) || webhookErr.Attempts != tt.wantAttempts || webhookErr.Body != "status body") {
+				t.Errorf("sendWebhookRequest() error = %#v, want WebhookError with %v attempts", err, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func Test_sendWebhookRequest_networkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	_, err := sendWebhookRequest(http.DefaultClient, webhookRequest{
+		URL:      server.URL,
+		Accepted: func(statusCode int) bool { return true },
+	}, retryPolicy{MaxRetries: 1})
+	var webhookErr *WebhookError
+	if !errors.As(err, &webhookErr) || webhookErr.Attempts != 2 || IsPermanent(err) {
+		t.Errorf("sendWebhookRequest() error = %#v, want temporary error after 2 attempts", err)
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "120", want: 2 * time.Minute},
+		{value: now.Add(30 * time.Second).Format(http.TimeFormat), want: 30 * time.Second},
+		{value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}